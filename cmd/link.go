@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	sigs "os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/afeedhshaji/signal-llm-bot/config"
+	signalapi "github.com/afeedhshaji/signal-llm-bot/internal/signal"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/qrterm"
+)
+
+// linkPollInterval is how often /v1/accounts is checked while waiting for the link
+var linkPollInterval = 2 * time.Second
+
+// runLink implements the link subcommand: it links the REST API as a new
+// device of an existing Signal account by showing the provisioning QR code
+// in the terminal, then waits until the account shows up in /v1/accounts.
+func runLink(args []string) {
+	apiURL := os.Getenv("SIGNAL_API_URL")
+	if cfg, err := config.LoadConfig(); err == nil {
+		apiURL = cfg.SignalAPIURL
+	}
+	if apiURL == "" {
+		apiURL = "http://localhost:8089"
+	}
+
+	fs := flag.NewFlagSet("link", flag.ExitOnError)
+	fs.StringVar(&apiURL, "api", apiURL, "signal-cli-rest-api URL")
+	deviceName := fs.String("name", "signal-llm-bot", "device name shown in the phone's linked devices")
+	number := fs.String("number", "", "number of the account being linked; by default any new account completes the link")
+	timeout := fs.Duration("timeout", 3*time.Minute, "how long to wait for the QR code to be scanned")
+	invert := fs.Bool("invert", false, "draw dark modules as blocks, for terminals with a light background")
+	fs.Parse(args)
+
+	ctx, cancel := sigs.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	client := signalapi.NewSignalClient(apiURL, "")
+
+	before, err := client.ListAccounts(ctx)
+	if err != nil {
+		log.Fatalf("Error listing accounts: %v", err)
+	}
+	if *number != "" && slices.Contains(before, *number) {
+		log.Fatalf("%s is already registered or linked with %s", *number, apiURL)
+	}
+
+	data, err := client.QRCodeLink(ctx, *deviceName)
+	if err != nil {
+		log.Fatalf("Error requesting QR code: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Fatalf("Error decoding QR code: %v", err)
+	}
+	qr, err := qrterm.Render(img, *invert)
+	if err != nil {
+		log.Fatalf("Error rendering QR code: %v (open %s/v1/qrcodelink?device_name=%s in a browser instead)", err, apiURL, *deviceName)
+	}
+	fmt.Print(qr)
+	fmt.Println("Scan this code in Signal on your phone: Settings > Linked devices > Link new device")
+
+	ctx, cancelWait := context.WithTimeout(ctx, *timeout)
+	defer cancelWait()
+	linked, err := waitForAccount(ctx, client, before, *number)
+	if err != nil {
+		log.Fatalf("Link not completed: %v", err)
+	}
+	fmt.Printf("Linked %s. Set SIGNAL_NUMBER=%s to serve it.\n", linked, linked)
+}
+
+// waitForAccount polls /v1/accounts until number, or any account not in
+// before when number is empty, is listed
+func waitForAccount(ctx context.Context, client *signalapi.SignalClient, before []string, number string) (string, error) {
+	ticker := time.NewTicker(linkPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		accounts, err := client.ListAccounts(ctx)
+		if err != nil {
+			log.Printf("Error listing accounts: %v", err)
+			continue
+		}
+		for _, a := range accounts {
+			if a == number || number == "" && !slices.Contains(before, a) {
+				return a, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	signalapi "github.com/afeedhshaji/signal-llm-bot/internal/signal"
+)
+
+// accountsServer serves /v1/accounts, listing linked after the first polls
+func accountsServer(t *testing.T, linked string, after int32) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/accounts" {
+			http.NotFound(w, r)
+			return
+		}
+		if calls.Add(1) > after {
+			w.Write([]byte(`["+100","` + linked + `"]`))
+			return
+		}
+		w.Write([]byte(`["+100"]`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func fastPoll(t *testing.T) {
+	t.Helper()
+	old := linkPollInterval
+	linkPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { linkPollInterval = old })
+}
+
+func TestWaitForAccount(t *testing.T) {
+	fastPoll(t)
+	srv := accountsServer(t, "+200", 2)
+	client := signalapi.NewSignalClient(srv.URL, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := waitForAccount(ctx, client, []string{"+100"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "+200" {
+		t.Errorf("waitForAccount() = %q, want +200", got)
+	}
+}
+
+func TestWaitForAccount_Number(t *testing.T) {
+	fastPoll(t)
+	srv := accountsServer(t, "+300", 0)
+	client := signalapi.NewSignalClient(srv.URL, "")
+
+	// +300 is new but not the number being linked; +100 was listed before.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if got, err := waitForAccount(ctx, client, []string{"+100"}, "+200"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waitForAccount() = %q, %v; want a timeout", got, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := waitForAccount(ctx, client, nil, "+300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "+300" {
+		t.Errorf("waitForAccount() = %q, want +300", got)
+	}
+}
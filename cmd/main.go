@@ -10,12 +10,18 @@ import (
 
 	"github.com/afeedhshaji/signal-llm-bot/config"
 	"github.com/afeedhshaji/signal-llm-bot/internal/bot"
+	signalapi "github.com/afeedhshaji/signal-llm-bot/internal/signal"
 	"github.com/afeedhshaji/signal-llm-bot/pkg/deduper"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/linkpreview"
 	"github.com/afeedhshaji/signal-llm-bot/pkg/openrouter"
-	signalapi "github.com/afeedhshaji/signal-llm-bot/internal/signal"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "link" {
+		runLink(os.Args[2:])
+		return
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
@@ -31,21 +37,122 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid OpenRouter timeout: %v", err)
 	}
+	groupCacheTTL, err := time.ParseDuration(cfg.GroupCacheTTL)
+	if err != nil {
+		log.Fatalf("Invalid group cache TTL: %v", err)
+	}
+	var signalTimeouts signalapi.Timeouts
+	for _, t := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"SIGNAL_RECEIVE_TIMEOUT", cfg.SignalReceiveTimeout, &signalTimeouts.Receive},
+		{"SIGNAL_SEND_TIMEOUT", cfg.SignalSendTimeout, &signalTimeouts.Send},
+		{"SIGNAL_UPLOAD_TIMEOUT", cfg.SignalUploadTimeout, &signalTimeouts.Upload},
+		{"SIGNAL_CONTROL_TIMEOUT", cfg.SignalControlTimeout, &signalTimeouts.Control},
+	} {
+		if *t.dst, err = time.ParseDuration(t.value); err != nil {
+			log.Fatalf("Invalid %s: %v", t.name, err)
+		}
+	}
 	deduperTTL := 30 * time.Second
 	dedup := deduper.New(deduperTTL)
 
-	signalClient := signalapi.NewSignalClient(cfg.SignalAPIURL, cfg.SignalNumber)
-	// Build OpenRouter client and wire it into the bot as the LLM
+	switch cfg.ReceiveMode {
+	case bot.ReceiveModePoll, bot.ReceiveModeWebSocket:
+	default:
+		log.Fatalf("Invalid receive mode %q (expected %q or %q)", cfg.ReceiveMode, bot.ReceiveModePoll, bot.ReceiveModeWebSocket)
+	}
+	switch cfg.LongReplyMode {
+	case bot.LongReplySplit, bot.LongReplyAttachment:
+	default:
+		log.Fatalf("Invalid long reply mode %q (expected %q or %q)", cfg.LongReplyMode, bot.LongReplySplit, bot.LongReplyAttachment)
+	}
+	if cfg.MaxMessageLength > 0 && cfg.MaxMessageLength < bot.MinMessageLength {
+		log.Fatalf("Invalid max message length %d (must be at least %d, or 0 to disable)", cfg.MaxMessageLength, bot.MinMessageLength)
+	}
+	switch cfg.TrustPolicy {
+	case bot.TrustOnFirstUse, bot.TrustUnverified, bot.TrustAlways:
+	default:
+		log.Fatalf("Invalid trust policy %q (expected %q, %q or %q)", cfg.TrustPolicy, bot.TrustOnFirstUse, bot.TrustUnverified, bot.TrustAlways)
+	}
+
+	// One connection pool and one OpenRouter client are shared by all accounts;
+	// accounts with the same model and system prompt share the same persona.
+	signalHTTP := signalapi.NewHTTPClient(int(cfg.SignalMaxConns))
 	openrouterEndpoint := "https://openrouter.ai/api/v1/chat/completions"
 	openrouterClient := openrouter.New(cfg.OpenRouterAPIKey, openrouterEndpoint, cfg.OpenRouterModel, openrouterTimeout, cfg.SystemPrompt)
+	personas := make(map[[2]string]*openrouter.Client)
+	var previews *linkpreview.Fetcher
+	if cfg.LinkPreviews {
+		timeout, err := time.ParseDuration(cfg.LinkPreviewTimeout)
+		if err != nil {
+			log.Fatalf("Invalid link preview timeout: %v", err)
+		}
+		previews = linkpreview.New(linkpreview.NewHTTPClient(timeout))
+	}
+
+	var bots []*bot.Bot
+	for _, account := range cfg.Accounts {
+		if account.Number == "" {
+			log.Fatalf("Signal number is not configured (set SIGNAL_NUMBER or ACCOUNT_1_NUMBER)")
+		}
+		// Signal profiles need a name; the profile is only applied when one is set.
+		if account.ProfileName == "" && (account.ProfileAbout != "" || account.ProfileAvatar != "") {
+			log.Fatalf("Profile about text or avatar is set for %s without a profile name (set PROFILE_NAME)", account.Number)
+		}
+		if account.ProfileName != "" && account.ProfileAvatar == "" {
+			log.Printf("No profile avatar is set for %s; applying the profile removes its current avatar", account.Number)
+		}
+		signalClient := signalapi.NewSignalClient(cfg.SignalAPIURL, account.Number)
+		signalClient.HTTPClient = signalHTTP
+		signalClient.Timeouts = signalTimeouts
 
-	botInstance := bot.NewBot(
-		signalClient,
-		openrouterClient,
-		pollInterval,
-		dedup,
-		cfg.SignalNumber,
-	)
+		key := [2]string{account.Model, account.SystemPrompt}
+		llmClient, ok := personas[key]
+		if !ok {
+			llmClient = openrouterClient.WithPersona(account.Model, account.SystemPrompt)
+			personas[key] = llmClient
+		}
+
+		botInstance := bot.NewBot(
+			signalClient,
+			llmClient,
+			pollInterval,
+			dedup,
+			account.Number,
+		)
+		botInstance.Name = account.Name
+		botInstance.Profile = signalapi.Profile{
+			Name:       account.ProfileName,
+			About:      account.ProfileAbout,
+			AvatarPath: account.ProfileAvatar,
+		}
+		botInstance.ReceiveMode = cfg.ReceiveMode
+		botInstance.Groups = signalapi.NewGroupDirectory(signalClient, groupCacheTTL)
+		botInstance.IgnoreSelf = cfg.IgnoreSelf
+		botInstance.MaxAttachmentSize = cfg.MaxAttachmentSize
+		botInstance.AllowViewOnce = cfg.AllowViewOnce
+		botInstance.ViewOnceGroups = cfg.ViewOnceGroups
+		botInstance.Admins = cfg.AdminNumbers
+		botInstance.TextStyles = cfg.TextStyles
+		botInstance.MentionAsker = cfg.MentionAsker
+		botInstance.SendReceipts = cfg.SendReceipts
+		botInstance.LinkPreviews = previews
+		botInstance.MaxMessageLength = int(cfg.MaxMessageLength)
+		botInstance.LongReplyMode = cfg.LongReplyMode
+		botInstance.TrustPolicy = cfg.TrustPolicy
+		if cfg.ReactionsEnabled {
+			botInstance.Reactions = bot.Reactions{
+				Accepted:  cfg.ReactionAccepted,
+				Succeeded: cfg.ReactionSucceeded,
+				Failed:    cfg.ReactionFailed,
+			}
+		}
+		bots = append(bots, botInstance)
+	}
+	supervisor := bot.NewSupervisor(bots...)
 
 	// Graceful shutdown with context
 	ctx, cancel := context.WithCancel(context.Background())
@@ -54,7 +161,7 @@ func main() {
 	done := make(chan struct{})
 
 	go func() {
-		botInstance.Start(ctx)
+		supervisor.Run(ctx)
 		close(done)
 	}()
 
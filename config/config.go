@@ -1,24 +1,65 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// Account is one Signal number served by the bot. Name, SystemPrompt and
+// Model default to BOT_NAME, SYSTEM_PROMPT and OPENROUTER_MODEL, the profile
+// fields to PROFILE_NAME, PROFILE_ABOUT and PROFILE_AVATAR.
+type Account struct {
+	Number        string
+	Name          string
+	SystemPrompt  string
+	Model         string
+	ProfileName   string
+	ProfileAbout  string
+	ProfileAvatar string
+}
+
 type Config struct {
-	SignalAPIURL      string
-	SignalNumber      string
-	BotName           string
-	PollInterval      string
-	GoogleAPIKey      string
-	GeminiModel       string
-	GeminiTimeout     string
-	SystemPrompt      string
-	OpenRouterAPIKey  string
-	OpenRouterModel   string
-	OpenRouterTimeout string
+	SignalAPIURL         string
+	SignalNumber         string
+	BotName              string
+	PollInterval         string
+	ReceiveMode          string
+	IgnoreSelf           bool
+	GoogleAPIKey         string
+	GeminiModel          string
+	GeminiTimeout        string
+	SystemPrompt         string
+	OpenRouterAPIKey     string
+	OpenRouterModel      string
+	OpenRouterTimeout    string
+	ReactionsEnabled     bool
+	ReactionAccepted     string
+	ReactionSucceeded    string
+	ReactionFailed       string
+	MaxAttachmentSize    int64
+	AdminNumbers         []string
+	TextStyles           bool
+	MentionAsker         bool
+	MaxMessageLength     int64
+	LongReplyMode        string
+	SendReceipts         bool
+	GroupCacheTTL        string
+	SignalMaxConns       int64
+	SignalReceiveTimeout string
+	SignalSendTimeout    string
+	SignalUploadTimeout  string
+	SignalControlTimeout string
+	TrustPolicy          string
+	AllowViewOnce        bool
+	ViewOnceGroups       []string
+	LinkPreviews         bool
+	LinkPreviewTimeout   string
+	Accounts             []Account
 }
 
 func LoadConfig() (*Config, error) {
@@ -28,19 +69,81 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	return &Config{
-		SignalAPIURL:      getEnv("SIGNAL_API_URL", "http://localhost:8089"),
-		SignalNumber:      getEnv("SIGNAL_NUMBER", ""),
-		BotName:           getEnv("BOT_NAME", ""),
-		PollInterval:      getEnv("POLL_INTERVAL", "5s"),
-		GoogleAPIKey:      getEnv("GOOGLE_API_KEY", ""),
-		GeminiModel:       getEnv("GEMINI_MODEL", "gemini-2.0-flash"),
-		GeminiTimeout:     getEnv("GEMINI_TIMEOUT", "120s"),
-		SystemPrompt:      getEnv("SYSTEM_PROMPT", "You are a helpful assistant."),
-		OpenRouterAPIKey:  getEnv("OPENROUTER_API_KEY", ""),
-		OpenRouterModel:   getEnv("OPENROUTER_MODEL", "xiaomi/mimo-v2-flash:free"),
-		OpenRouterTimeout: getEnv("OPENROUTER_TIMEOUT", "120s"),
-	}, nil
+	cfg := &Config{
+		SignalAPIURL:         getEnv("SIGNAL_API_URL", "http://localhost:8089"),
+		SignalNumber:         getEnv("SIGNAL_NUMBER", ""),
+		BotName:              getEnv("BOT_NAME", ""),
+		PollInterval:         getEnv("POLL_INTERVAL", "5s"),
+		ReceiveMode:          getEnv("RECEIVE_MODE", "poll"),
+		IgnoreSelf:           getEnvBool("IGNORE_SELF", true),
+		GoogleAPIKey:         getEnv("GOOGLE_API_KEY", ""),
+		GeminiModel:          getEnv("GEMINI_MODEL", "gemini-2.0-flash"),
+		GeminiTimeout:        getEnv("GEMINI_TIMEOUT", "120s"),
+		SystemPrompt:         getEnv("SYSTEM_PROMPT", "You are a helpful assistant."),
+		OpenRouterAPIKey:     getEnv("OPENROUTER_API_KEY", ""),
+		OpenRouterModel:      getEnv("OPENROUTER_MODEL", "xiaomi/mimo-v2-flash:free"),
+		OpenRouterTimeout:    getEnv("OPENROUTER_TIMEOUT", "120s"),
+		ReactionsEnabled:     getEnvBool("REACTIONS_ENABLED", false),
+		ReactionAccepted:     getEnv("REACTION_ACCEPTED", "👀"),
+		ReactionSucceeded:    getEnv("REACTION_SUCCEEDED", "✅"),
+		ReactionFailed:       getEnv("REACTION_FAILED", "❌"),
+		MaxAttachmentSize:    getEnvInt64("MAX_ATTACHMENT_SIZE", 10<<20),
+		AdminNumbers:         getEnvList("ADMIN_NUMBERS"),
+		TextStyles:           getEnvBool("TEXT_STYLES", true),
+		MentionAsker:         getEnvBool("MENTION_ASKER", true),
+		MaxMessageLength:     getEnvInt64("MAX_MESSAGE_LENGTH", 2000),
+		LongReplyMode:        getEnv("LONG_REPLY_MODE", "split"),
+		SendReceipts:         getEnvBool("SEND_RECEIPTS", true),
+		GroupCacheTTL:        getEnv("GROUP_CACHE_TTL", "10m"),
+		SignalMaxConns:       getEnvInt64("SIGNAL_MAX_CONNS", 16),
+		SignalReceiveTimeout: getEnv("SIGNAL_RECEIVE_TIMEOUT", "15s"),
+		SignalSendTimeout:    getEnv("SIGNAL_SEND_TIMEOUT", "10s"),
+		SignalUploadTimeout:  getEnv("SIGNAL_UPLOAD_TIMEOUT", "60s"),
+		SignalControlTimeout: getEnv("SIGNAL_CONTROL_TIMEOUT", "10s"),
+		TrustPolicy:          getEnv("TRUST_POLICY", "tofu"),
+		AllowViewOnce:        getEnvBool("ALLOW_VIEW_ONCE", false),
+		ViewOnceGroups:       getEnvList("VIEW_ONCE_GROUPS"),
+		LinkPreviews:         getEnvBool("LINK_PREVIEWS", true),
+		LinkPreviewTimeout:   getEnv("LINK_PREVIEW_TIMEOUT", "5s"),
+	}
+	cfg.Accounts = loadAccounts(Account{
+		Number:        cfg.SignalNumber,
+		Name:          cfg.BotName,
+		SystemPrompt:  cfg.SystemPrompt,
+		Model:         cfg.OpenRouterModel,
+		ProfileName:   getEnv("PROFILE_NAME", ""),
+		ProfileAbout:  getEnv("PROFILE_ABOUT", ""),
+		ProfileAvatar: getEnv("PROFILE_AVATAR", ""),
+	})
+	return cfg, nil
+}
+
+// loadAccounts reads ACCOUNT_1_NUMBER, ACCOUNT_2_NUMBER, ... and their
+// ACCOUNT_<n>_NAME, _SYSTEM_PROMPT, _MODEL and _PROFILE_* overrides,
+// stopping at the first missing number. Without any, the single account from
+// SIGNAL_NUMBER is used.
+func loadAccounts(defaults Account) []Account {
+	var accounts []Account
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("ACCOUNT_%d_", i)
+		number := getEnv(prefix+"NUMBER", "")
+		if number == "" {
+			break
+		}
+		accounts = append(accounts, Account{
+			Number:        number,
+			Name:          getEnv(prefix+"NAME", defaults.Name),
+			SystemPrompt:  getEnv(prefix+"SYSTEM_PROMPT", defaults.SystemPrompt),
+			Model:         getEnv(prefix+"MODEL", defaults.Model),
+			ProfileName:   getEnv(prefix+"PROFILE_NAME", defaults.ProfileName),
+			ProfileAbout:  getEnv(prefix+"PROFILE_ABOUT", defaults.ProfileAbout),
+			ProfileAvatar: getEnv(prefix+"PROFILE_AVATAR", defaults.ProfileAvatar),
+		})
+	}
+	if len(accounts) == 0 {
+		accounts = append(accounts, defaults)
+	}
+	return accounts
 }
 
 func getEnv(key, fallback string) string {
@@ -49,3 +152,39 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s: %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid integer for %s: %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
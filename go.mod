@@ -3,3 +3,5 @@ module github.com/afeedhshaji/signal-llm-bot
 go 1.25
 
 require github.com/joho/godotenv v1.4.0
+
+require github.com/gorilla/websocket v1.5.3
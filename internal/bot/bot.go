@@ -5,47 +5,152 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/afeedhshaji/signal-llm-bot/internal/bot/format"
 	"github.com/afeedhshaji/signal-llm-bot/internal/bot/message"
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
 	"github.com/afeedhshaji/signal-llm-bot/pkg/deduper"
 	"github.com/afeedhshaji/signal-llm-bot/pkg/igdownloader"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/linkpreview"
 	"github.com/afeedhshaji/signal-llm-bot/pkg/llm"
-	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
 )
 
+// typingRefreshInterval is how often the typing indicator is re-sent; Signal
+// clients hide it after roughly 15 seconds without a refresh
+const typingRefreshInterval = 10 * time.Second
+
+// Long reply modes: split into numbered parts or send as a file attachment
+const (
+	LongReplySplit      = "split"
+	LongReplyAttachment = "attachment"
+)
+
+// DefaultMaxMessageLength is the largest reply, in bytes, sent as one message
+const DefaultMaxMessageLength = 2000
+
+// DefaultGroupCacheTTL is how long group metadata is cached before reloading
+const DefaultGroupCacheTTL = 10 * time.Minute
+
+// partHeaderReserve leaves room for the mention and "(i/n) " part numbering
+const partHeaderReserve = 16
+
+// maxInlineText is how much of a text attachment is included in the prompt
+const maxInlineText = 8 << 10
+
+// MinMessageLength is the smallest MaxMessageLength that leaves room for text
+// after the part header
+const MinMessageLength = 64
+
+// Receive modes supported by Bot.Start
+const (
+	ReceiveModePoll      = "poll"
+	ReceiveModeWebSocket = "websocket"
+)
+
+// Reactions holds the emoji the bot uses to acknowledge a request; an empty
+// emoji disables that reaction
+type Reactions struct {
+	Accepted  string
+	Succeeded string
+	Failed    string
+}
+
 type Bot struct {
-	SignalClient *signal.SignalClient
+	SignalClient signal.Transport
+	Groups       *signal.GroupDirectory
 	LLMClient    llm.LLM
 	PollInterval time.Duration
+	ReceiveMode  string
 	Deduper      *deduper.Deduper
 	BotNumber    string
 	BotUUID      string
+	Name         string // display name of the account, used in logs and help
 	IgnoreSelf   bool
+	Reactions    Reactions
+	Admins       []string // numbers or UUIDs allowed to run privileged commands
+	TextStyles   bool     // render Markdown in replies as Signal text styles
+	MentionAsker bool     // start group replies with an @mention of the asker
+	SendReceipts bool     // send read/viewed receipts for handled messages
+	// MaxMessageLength is the largest reply in bytes; longer replies are
+	// handled according to LongReplyMode. Zero disables the limit.
+	MaxMessageLength int
+	LongReplyMode    string
+	// MaxAttachmentSize caps attachment downloads in bytes; zero disables the cap
+	MaxAttachmentSize int64
+	// AllowViewOnce lets view-once attachments reach the LLM and commands;
+	// by default they are never downloaded
+	AllowViewOnce bool
+	// ViewOnceGroups lists groups, by internal or public ID, where images and
+	// videos the bot sends are view-once
+	ViewOnceGroups []string
+	// Profile is applied to the account when the bot starts if Name is set,
+	// and can be changed by admins with /profile
+	Profile signal.Profile
+	// TrustPolicy decides how changed safety numbers are handled: TrustOnFirstUse,
+	// TrustUnverified or TrustAlways
+	TrustPolicy string
+	// LinkPreviews builds previews of links in replies; nil disables fetching
+	LinkPreviews *linkpreview.Fetcher
+
+	replies        *replyLog
+	profileApplied bool
+	pendingTrust   map[string]bool // contacts waiting for an admin's /trust
+	verified       map[string]bool // contacts whose key was verified, by number or UUID
 }
 
-func NewBot(signalClient *signal.SignalClient, llmClient llm.LLM, pollInterval time.Duration,
+func NewBot(signalClient signal.Transport, llmClient llm.LLM, pollInterval time.Duration,
 	deduper *deduper.Deduper, botNumber string) *Bot {
 	return &Bot{
-		SignalClient: signalClient,
-		LLMClient:    llmClient,
-		PollInterval: pollInterval,
-		Deduper:      deduper,
-		BotNumber:    botNumber,
+		SignalClient:     signalClient,
+		Groups:           signal.NewGroupDirectory(signalClient, DefaultGroupCacheTTL),
+		LLMClient:        llmClient,
+		PollInterval:     pollInterval,
+		ReceiveMode:      ReceiveModePoll,
+		MaxMessageLength: DefaultMaxMessageLength,
+		LongReplyMode:    LongReplySplit,
+		TrustPolicy:      TrustOnFirstUse,
+		Deduper:          deduper,
+		BotNumber:        botNumber,
+		replies:          newReplyLog(),
+		pendingTrust:     make(map[string]bool),
+		verified:         make(map[string]bool),
 	}
 }
 
-// Start begins the bot's polling loop and stops when context is cancelled
+// Start begins receiving messages in the configured mode and stops when context is cancelled
 func (b *Bot) Start(ctx context.Context) {
+	if b.BotUUID == "" {
+		b.discoverUUID(ctx)
+	}
+	if b.TrustPolicy == TrustUnverified {
+		b.loadVerified(ctx)
+	}
+	if b.Profile.Name != "" && !b.profileApplied {
+		if err := b.SignalClient.UpdateProfile(ctx, b.Profile); err != nil {
+			log.Printf("Error updating profile of %s: %v", b.label(), err)
+		} else {
+			b.profileApplied = true
+		}
+	}
+	if b.ReceiveMode == ReceiveModeWebSocket {
+		b.stream(ctx)
+		return
+	}
+
 	ticker := time.NewTicker(b.PollInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			b.handleMessages()
+			b.handleMessages(ctx)
 		case <-ctx.Done():
 			log.Println("bot: context cancelled, stopping polling loop")
 			return
@@ -53,189 +158,863 @@ func (b *Bot) Start(ctx context.Context) {
 	}
 }
 
+// stream processes messages from the websocket receive stream until context is cancelled
+func (b *Bot) stream(ctx context.Context) {
+	err := b.SignalClient.StreamEvents(ctx, func(ev signal.Envelope) {
+		b.handleEvent(ctx, ev)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Receive stream stopped: %v", err)
+		return
+	}
+	log.Println("bot: context cancelled, stopping receive stream")
+}
+
+// discoverUUID looks up the account's own UUID so that UUID-only mentions and
+// the bot's own messages are recognised. If the REST API does not report it,
+// it is learned from the first sync message instead.
+func (b *Bot) discoverUUID(ctx context.Context) {
+	uuid, err := b.SignalClient.SelfUUID(ctx)
+	if err != nil {
+		log.Printf("Could not discover UUID of %s, mentions by UUID are missed until a sync message arrives: %v", b.label(), err)
+		return
+	}
+	log.Printf("Discovered UUID of %s: %s", b.label(), uuid)
+	b.BotUUID = uuid
+}
+
+// learnUUID takes the bot's UUID from a message sent by its own account on a
+// linked device, when it is not known yet
+func (b *Bot) learnUUID(ev signal.Envelope) {
+	if b.BotUUID != "" || ev.SyncMessage == nil || ev.SourceUUID == "" {
+		return
+	}
+	if message.NormalizePhone(ev.SourceNumber) != message.NormalizePhone(b.BotNumber) {
+		return
+	}
+	log.Printf("Learned UUID of %s from sync message: %s", b.label(), ev.SourceUUID)
+	b.BotUUID = ev.SourceUUID
+}
+
 // handleMessages fetches and processes new messages
-func (b *Bot) handleMessages() {
-	events, err := b.SignalClient.ReceiveEvents()
+func (b *Bot) handleMessages(ctx context.Context) {
+	events, err := b.SignalClient.ReceiveEvents(ctx)
 	if err != nil {
 		log.Printf("Error receiving events: %v", err)
 		return
 	}
 
 	for _, ev := range events {
-		evb, _ := json.Marshal(ev)
-		hash := sha1.Sum(evb)
-		hashStr := hex.EncodeToString(hash[:])
-		if b.Deduper.Seen(hashStr) {
-			log.Printf("skipping duplicate (hash=%s)", hashStr)
-			continue
-		}
+		b.handleEvent(ctx, ev)
+	}
+}
 
-		msg := message.SimpleExtract(&ev, b.BotNumber, b.BotUUID)
-		msg.EventHash = hashStr
-		msg.RawEvent = &ev
+// handleEvent processes a single envelope, regardless of how it was received
+func (b *Bot) handleEvent(ctx context.Context, ev signal.Envelope) {
+	// Several accounts may receive the same group message, so the account is
+	// part of the key when they share a deduper.
+	evb, _ := json.Marshal(ev)
+	hash := sha1.Sum(append([]byte(b.BotNumber+"\n"), evb...))
+	hashStr := hex.EncodeToString(hash[:])
+	if b.Deduper.Seen(hashStr) {
+		log.Printf("skipping duplicate (hash=%s)", hashStr)
+		return
+	}
 
-		if !msg.BotMentioned {
-			continue
-		}
+	b.learnUUID(ev)
+	msg := message.SimpleExtract(&ev, b.BotNumber, b.BotUUID)
+	msg.EventHash = hashStr
+	msg.RawEvent = &ev
 
-		log.Printf("Mentioned in %s -> %q", message.TargetLabel(msg), msg.CleanText)
+	if b.IgnoreSelf && b.isOwnOutput(msg) {
+		log.Printf("skipping own message %d", msg.Timestamp)
+		return
+	}
 
-		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(msg.CleanText)), "/help") {
-			b.handleHelpCommand(msg)
-			return
-		}
+	// The operator can run commands from their own linked devices, in any chat.
+	command := commandName(msg.CleanText)
+	if msg.Sync && command != "" {
+		msg.BotMentioned = true
+	}
+	// Stickers cannot carry mentions, so quoting one of the bot's replies
+	// addresses them to it, in direct chats as in groups, like text.
+	if msg.Sticker != nil && !msg.Sync && b.quotesBot(msg) {
+		msg.BotMentioned = true
+	}
 
-		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(msg.CleanText)), "/download") {
-			var instagramURL string
+	if msg.Reaction != nil {
+		log.Printf("Reaction %q from %s on message %d (removed=%v)", msg.Reaction.Emoji,
+			message.TargetLabel(msg), msg.Reaction.TargetSentTimestamp, msg.Reaction.IsRemove)
+		return
+	}
 
-			commandText := strings.TrimSpace(msg.CleanText[9:])
-			if commandText != "" {
-				instagramURL = igdownloader.ExtractInstagramURL(commandText)
-			}
+	if !msg.BotMentioned {
+		return
+	}
 
-			if instagramURL == "" {
-				instagramURL = igdownloader.ExtractInstagramURL(msg.RawText)
-			}
+	if msg.Edited {
+		log.Printf("Edited message %d in %s, answering again", msg.Timestamp, message.TargetLabel(msg))
+	}
+	log.Printf("Mentioned in %s -> %s", message.TargetLabel(msg), loggable(msg, msg.CleanText))
+	b.sendReceipt(ctx, msg)
 
-			if instagramURL == "" && msg.Quote != nil && msg.Quote.Text != "" {
-				instagramURL = igdownloader.ExtractInstagramURL(msg.Quote.Text)
-			}
+	if command == "/help" {
+		b.handleHelpCommand(ctx, msg)
+		return
+	}
 
-			if instagramURL != "" {
-				b.handleInstagramDownload(msg, instagramURL)
-				return
-			}
+	if command == "/delete" {
+		b.handleDeleteCommand(ctx, msg)
+		return
+	}
 
-			usage := "To download an Instagram video:\\n• Reply to a message containing an Instagram URL with '@bot /download'\\n• Or use '@bot /download <instagram_url>'"
-			b.sendResponse(msg, usage)
-			return
+	if command == "/trust" {
+		b.handleTrustCommand(ctx, msg)
+		return
+	}
+
+	if command == "/profile" {
+		b.handleProfileCommand(ctx, msg)
+		return
+	}
+
+	if command == "/download" {
+		var instagramURL string
+
+		commandText := strings.TrimSpace(strings.TrimSpace(msg.CleanText)[len("/download"):])
+		if commandText != "" {
+			instagramURL = igdownloader.ExtractInstagramURL(commandText)
 		}
 
-		prompt := msg.CleanText
-		if msg.Quote != nil && msg.Quote.Text != "" {
-			prompt = "Context (replying to): \"" + msg.Quote.Text + "\"\n\nUser message: " + msg.CleanText
-			log.Printf("Including reply context from %s: %q", msg.Quote.Author, msg.Quote.Text)
+		if instagramURL == "" {
+			instagramURL = igdownloader.ExtractInstagramURL(msg.RawText)
 		}
 
-		response, err := b.LLMClient.Ask(prompt)
-		if err != nil {
-			log.Printf("Error generating LLM response: %v", err)
-			b.sendErrorResponse(msg)
+		if instagramURL == "" && msg.Quote != nil && msg.Quote.Text != "" {
+			instagramURL = igdownloader.ExtractInstagramURL(msg.Quote.Text)
+		}
+
+		if instagramURL != "" {
+			b.handleInstagramDownload(ctx, msg, instagramURL)
 			return
 		}
 
-		b.sendResponse(msg, response)
+		usage := "To download an Instagram video:\\n• Reply to a message containing an Instagram URL with '@bot /download'\\n• Or use '@bot /download <instagram_url>'"
+		b.sendResponse(ctx, msg, usage)
+		return
+	}
+
+	// Anything else the operator writes to other chats is theirs, not the bot's.
+	if msg.Sync && !b.isNoteToSelf(msg) {
+		return
+	}
+
+	prompt := msg.CleanText
+	if msg.Quote != nil && msg.Quote.Text != "" {
+		prompt = "Context (replying to): \"" + msg.Quote.Text + "\"\n\nUser message: " + msg.CleanText
+		log.Printf("Including reply context from %s: %s", msg.Quote.Author, loggable(msg, msg.Quote.Text))
+	}
+	if msg.Sticker != nil {
+		prompt = strings.TrimSpace(prompt + "\n\n" + describeSticker(msg.Sticker))
+	}
+	if attachments := b.attachmentsOf(msg); len(attachments) > 0 {
+		prompt += b.describeAttachments(ctx, attachments)
+	} else if msg.ViewOnce {
+		prompt += "\n\n[User attached view-once media, which is not shared with you]"
+	}
+
+	b.react(ctx, msg, b.Reactions.Accepted)
+	stopTyping := b.showTyping(ctx, msg)
+	response, err := b.LLMClient.Ask(prompt)
+	stopTyping()
+	if err != nil {
+		log.Printf("Error generating LLM response: %v", err)
+		b.react(ctx, msg, b.Reactions.Failed)
+		b.sendErrorResponse(ctx, msg)
+		return
+	}
+
+	if !b.reply(ctx, msg, response) {
+		b.react(ctx, msg, b.Reactions.Failed)
+		return
+	}
+	b.react(ctx, msg, b.Reactions.Succeeded)
+}
+
+// reply answers msg and reports whether the answer was delivered. When msg is
+// an edit of a request the bot already answered, the earlier answer is
+// revised in place instead.
+func (b *Bot) reply(ctx context.Context, msg message.Message, response string) bool {
+	key := replyKey{Author: authorOf(msg), Timestamp: msg.Timestamp}
+	if msg.Edited {
+		if replyTS, ok := b.replies.Lookup(key); ok && b.editResponse(ctx, msg, replyTS, response) {
+			return true
+		}
 	}
+	_, ok := b.sendResponse(ctx, msg, response)
+	return ok
 }
 
-// sendResponse sends a text response to the appropriate chat
-func (b *Bot) sendResponse(msg message.Message, response string) {
-	var quote *signal.QuoteRequest
-	if msg.RawEvent != nil && msg.RawEvent.Timestamp > 0 {
-		author := msg.SourceNumber
-		if author == "" {
-			author = msg.SourceUUID
+// sendResponse sends a text response to the appropriate chat and reports
+// whether it was sent, with the timestamp of the sent message when known.
+// Responses longer than MaxMessageLength are split into numbered parts or
+// sent as a file.
+func (b *Bot) sendResponse(ctx context.Context, msg message.Message, response string) (int64, bool) {
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient: %v", err)
+		return 0, false
+	}
+
+	text, styled := b.render(response)
+	if b.fits(text) {
+		return b.deliver(ctx, msg, recipient, b.withPreview(ctx, msg, b.compose(msg, text, styled)))
+	}
+	if b.LongReplyMode == LongReplyAttachment {
+		return b.sendAsAttachment(ctx, msg, response)
+	}
+
+	parts := format.Split(text, b.MaxMessageLength-partHeaderReserve, styled)
+	log.Printf("Splitting %d byte response into %d parts", len(text), len(parts))
+	var first int64
+	sent, previewed := false, false
+	for i, part := range parts {
+		numbered := fmt.Sprintf("(%d/%d) %s", i+1, len(parts), part)
+		out := signal.OutgoingMessage{Text: numbered, Styled: styled, ExpiresInSeconds: msg.ExpiresInSeconds}
+		if i == 0 {
+			out = b.compose(msg, numbered, styled)
+		}
+		// Only the first part with a link gets a preview.
+		if !previewed && linkpreview.FirstURL(part) != "" {
+			out = b.withPreview(ctx, msg, out)
+			previewed = true
 		}
-		quote = &signal.QuoteRequest{
-			ID:     msg.RawEvent.Timestamp,
-			Author: author,
-			Text:   msg.RawText,
+		ts, ok := b.deliver(ctx, msg, recipient, out)
+		if !ok {
+			break
+		}
+		if i == 0 {
+			first, sent = ts, true
 		}
 	}
+	return first, sent
+}
 
-	if msg.GroupID != "" {
-		publicID, err := b.SignalClient.GetGroupPublicID(msg.GroupID)
-		if err != nil {
-			log.Printf("Error getting public group ID: %v", err)
-			return
+// deliver sends out to recipient, records it as a reply to msg and reports
+// whether it was sent. The timestamp is zero when the REST API did not report it.
+func (b *Bot) deliver(ctx context.Context, msg message.Message, recipient string, out signal.OutgoingMessage) (int64, bool) {
+	ts, err := b.SignalClient.Send(ctx, recipient, out)
+	var untrusted *signal.UntrustedIdentityError
+	if errors.As(err, &untrusted) && b.resolveUntrusted(ctx, msg, untrusted) {
+		// In a group signal-cli has already delivered to the other members,
+		// so sending again would give them the message twice.
+		if msg.GroupID != "" {
+			log.Printf("Reply in %s missed %s, whose new safety number is now trusted", message.TargetLabel(msg), untrusted.Number)
+			return 0, false
 		}
-		err = b.SignalClient.SendMessageWithQuote(publicID, response, quote)
-		if err != nil {
-			log.Printf("Error sending message to group: %v", err)
+		ts, err = b.SignalClient.Send(ctx, recipient, out)
+	}
+	if err != nil {
+		what := "message"
+		if len(out.Attachments) > 0 {
+			what = "file"
 		}
-	} else if msg.SourceNumber != "" {
-		if err := b.SignalClient.SendMessageWithQuote(msg.SourceNumber, response, quote); err != nil {
-			log.Printf("Error sending message to user: %v", err)
+		logSendError(what, msg, err)
+		return 0, false
+	}
+	if ts != 0 {
+		ttl := time.Duration(msg.ExpiresInSeconds) * time.Second
+		b.replies.Record(replyKey{Author: authorOf(msg), Timestamp: msg.Timestamp}, ts, ttl)
+	}
+	return ts, true
+}
+
+// logSendError logs a failed send, calling out failures that need the
+// operator's attention rather than a retry
+func logSendError(what string, msg message.Message, err error) {
+	var proof *signal.ProofRequiredError
+	var rateLimited *signal.RateLimitError
+	var unregistered *signal.UnregisteredRecipientError
+	var untrusted *signal.UntrustedIdentityError
+	switch {
+	case errors.As(err, &proof):
+		log.Printf("Signal requires a captcha challenge before sending again (token %q); dropped %s to %s", proof.Token, what, message.TargetLabel(msg))
+	case errors.As(err, &rateLimited):
+		log.Printf("Rate limited by Signal (retry after %s); dropped %s to %s", rateLimited.RetryAfter, what, message.TargetLabel(msg))
+	case errors.As(err, &untrusted):
+		log.Printf("Safety number of %s changed and is not trusted; dropped %s to %s", untrusted.Number, what, message.TargetLabel(msg))
+	case errors.As(err, &unregistered):
+		log.Printf("Recipient %s is not registered with Signal; dropped %s", message.TargetLabel(msg), what)
+	default:
+		log.Printf("Error sending %s to %s: %v", what, message.TargetLabel(msg), err)
+	}
+}
+
+// sendAsAttachment sends response as a Markdown file, or a plain text file
+// when text styles are disabled
+func (b *Bot) sendAsAttachment(ctx context.Context, msg message.Message, response string) (int64, bool) {
+	ext, content := ".md", response
+	if !b.TextStyles {
+		ext, content = ".txt", format.Plain(response)
+	}
+	f, err := os.CreateTemp("", "reply-*"+ext)
+	if err != nil {
+		log.Printf("Error creating reply file: %v", err)
+		return 0, false
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("Error writing reply file: %v", err)
+		return 0, false
+	}
+	return b.sendFile(ctx, msg, f.Name(), "📄 The answer is too long for one message, so it is attached as a file.")
+}
+
+// editResponse replaces the text of the bot's reply to msg that was sent at
+// replyTimestamp and reports whether the edit was delivered. Responses too
+// long for a single message are not edited in.
+func (b *Bot) editResponse(ctx context.Context, msg message.Message, replyTimestamp int64, response string) bool {
+	text, styled := b.render(response)
+	if !b.fits(text) {
+		return false
+	}
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient: %v", err)
+		return false
+	}
+	edit := b.withPreview(ctx, msg, b.compose(msg, text, styled))
+	edit.EditTimestamp = replyTimestamp
+	if _, err := b.SignalClient.Send(ctx, recipient, edit); err != nil {
+		log.Printf("Error editing message %d in %s: %v", replyTimestamp, message.TargetLabel(msg), err)
+		return false
+	}
+	return true
+}
+
+// updateResponse edits the reply sent at replyTimestamp, falling back to a new
+// message when there is no earlier reply or the edit fails
+func (b *Bot) updateResponse(ctx context.Context, msg message.Message, replyTimestamp int64, response string) {
+	if replyTimestamp != 0 && b.editResponse(ctx, msg, replyTimestamp, response) {
+		return
+	}
+	b.sendResponse(ctx, msg, response)
+}
+
+// render converts the Markdown in response to Signal text styles, or strips
+// it when styles are disabled, and reports whether the result is styled
+func (b *Bot) render(response string) (string, bool) {
+	if b.TextStyles {
+		return format.Styled(response)
+	}
+	return format.Plain(response), false
+}
+
+// fits reports whether rendered text can be sent as a single message
+func (b *Bot) fits(text string) bool {
+	return b.MaxMessageLength <= 0 || len(text)+partHeaderReserve <= b.MaxMessageLength
+}
+
+// compose builds the outgoing reply to msg from rendered text. Group replies
+// start with a mention of the asker when MentionAsker is set.
+func (b *Bot) compose(msg message.Message, text string, styled bool) signal.OutgoingMessage {
+	out := signal.OutgoingMessage{Text: text, Quote: quoteFor(msg), Styled: styled, ExpiresInSeconds: msg.ExpiresInSeconds}
+	if b.MentionAsker && msg.GroupID != "" {
+		asker := msg.SourceUUID
+		if asker == "" {
+			asker = msg.SourceNumber
 		}
-	} else if msg.SourceUUID != "" {
-		if err := b.SignalClient.SendMessageWithQuote(msg.SourceUUID, response, quote); err != nil {
-			log.Printf("Error sending message to user-uuid: %v", err)
+		if asker != "" {
+			text, mention := signal.MentionAt(" "+out.Text, 0, asker)
+			out.Text = text
+			out.Mentions = []signal.MentionRequest{mention}
 		}
 	}
+	return out
+}
+
+// withPreview adds a preview of the first link in out's text, the reply to
+// msg, when link previews are enabled. Replies are sent without one if it
+// cannot be built.
+func (b *Bot) withPreview(ctx context.Context, msg message.Message, out signal.OutgoingMessage) signal.OutgoingMessage {
+	if b.LinkPreviews == nil {
+		return out
+	}
+	link := linkpreview.FirstURL(out.Text)
+	if link == "" {
+		return out
+	}
+	p, err := b.LinkPreviews.Fetch(ctx, link)
+	if err != nil {
+		// Fetch errors name the link as well.
+		log.Printf("No link preview: %s", loggable(msg, fmt.Sprintf("%s: %v", link, err)))
+		return out
+	}
+	out.LinkPreview = &signal.LinkPreview{URL: p.URL, Title: p.Title, Description: p.Description, Thumbnail: p.Image}
+	return out
+}
+
+// loggable returns text quoted for a log line, or a placeholder when msg is
+// from a chat with disappearing messages, whose content must not outlive it
+func loggable(msg message.Message, text string) string {
+	if msg.ExpiresInSeconds > 0 {
+		return fmt.Sprintf("[disappearing message, %ds]", msg.ExpiresInSeconds)
+	}
+	return fmt.Sprintf("%q", text)
+}
+
+// quoteFor builds the quote that ties a reply to msg; edits quote the original message
+func quoteFor(msg message.Message) *signal.QuoteRequest {
+	if msg.Timestamp <= 0 {
+		return nil
+	}
+	return &signal.QuoteRequest{
+		ID:     msg.Timestamp,
+		Author: authorOf(msg),
+		Text:   msg.RawText,
+	}
+}
+
+// authorOf returns the sender address of msg, preferring the phone number
+func authorOf(msg message.Message) string {
+	if msg.SourceNumber != "" {
+		return msg.SourceNumber
+	}
+	return msg.SourceUUID
 }
 
 // sendErrorResponse sends a generic error message to the chat
-func (b *Bot) sendErrorResponse(msg message.Message) {
+func (b *Bot) sendErrorResponse(ctx context.Context, msg message.Message) {
 	generic := "An error occurred while processing your request. Please try again later."
-	b.sendResponse(msg, generic)
+	b.reply(ctx, msg, generic)
 }
 
-// sendFile sends a file to the appropriate chat
-func (b *Bot) sendFile(msg message.Message, filePath, caption string) {
-	var quote *signal.QuoteRequest
-	if msg.RawEvent != nil && msg.RawEvent.Timestamp > 0 {
-		author := msg.SourceNumber
-		if author == "" {
-			author = msg.SourceUUID
-		}
-		quote = &signal.QuoteRequest{
-			ID:     msg.RawEvent.Timestamp,
-			Author: author,
-			Text:   msg.RawText,
+// sendFile sends a file to the appropriate chat and reports whether it was
+// sent, with the timestamp of the sent message when known
+func (b *Bot) sendFile(ctx context.Context, msg message.Message, filePath, caption string) (int64, bool) {
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient: %v", err)
+		return 0, false
+	}
+	out := signal.OutgoingMessage{
+		Text:             caption,
+		Quote:            quoteFor(msg),
+		Attachments:      []string{filePath},
+		ExpiresInSeconds: msg.ExpiresInSeconds,
+		ViewOnce:         b.viewOnceIn(msg, recipient) && isMedia(filePath),
+	}
+	return b.deliver(ctx, msg, recipient, out)
+}
+
+// describeSticker tells the LLM which sticker the user sent, by its emoji
+// when the sender's client included one
+func describeSticker(s *signal.Sticker) string {
+	if s.Emoji != "" {
+		return "[User sent a " + s.Emoji + " sticker]"
+	}
+	return "[User sent a sticker]"
+}
+
+// attachmentsOf returns the attachments of msg the bot may process. View-once
+// media is withheld unless AllowViewOnce is set.
+func (b *Bot) attachmentsOf(msg message.Message) []signal.Attachment {
+	if msg.ViewOnce && !b.AllowViewOnce {
+		return nil
+	}
+	return msg.Attachments
+}
+
+// viewOnceIn reports whether media sent in reply to msg should be view-once
+func (b *Bot) viewOnceIn(msg message.Message, recipient string) bool {
+	if msg.GroupID == "" {
+		return false
+	}
+	for _, g := range b.ViewOnceGroups {
+		if g == msg.GroupID || g == recipient {
+			return true
 		}
 	}
+	return false
+}
 
-	if msg.GroupID != "" {
-		publicID, err := b.SignalClient.GetGroupPublicID(msg.GroupID)
-		if err != nil {
-			log.Printf("Error getting public group ID: %v", err)
-			return
+// isMedia reports whether the file is an image or video, the only
+// attachments Signal shows as view-once
+func isMedia(filePath string) bool {
+	t := signal.ContentType(filePath)
+	return strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "video/")
+}
+
+// describeAttachments renders attachments as prompt context. Text attachments
+// are inlined; everything else is described by type and name.
+func (b *Bot) describeAttachments(ctx context.Context, attachments []signal.Attachment) string {
+	var sb strings.Builder
+	for _, a := range attachments {
+		name := a.Filename
+		if name == "" {
+			name = a.ID
 		}
-		err = b.SignalClient.SendFileWithQuote(publicID, filePath, caption, quote)
+		if !strings.HasPrefix(a.ContentType, "text/") {
+			sb.WriteString(fmt.Sprintf("\n\n[User attached %s (%s, %d bytes)]", name, a.ContentType, a.Size))
+			continue
+		}
+		content, err := b.readAttachment(ctx, a)
 		if err != nil {
-			log.Printf("Error sending file to group: %v", err)
+			log.Printf("Error reading attachment %s: %v", a.ID, err)
+			sb.WriteString(fmt.Sprintf("\n\n[User attached %s, which could not be read]", name))
+			continue
 		}
-	} else if msg.SourceNumber != "" {
-		if err := b.SignalClient.SendFileWithQuote(msg.SourceNumber, filePath, caption, quote); err != nil {
-			log.Printf("Error sending file to user: %v", err)
+		text := string(content)
+		if len(content) > maxInlineText {
+			text = strings.ToValidUTF8(text[:maxInlineText], "") +
+				fmt.Sprintf("\n[Truncated: showing the first %d of %d bytes]", maxInlineText, len(content))
 		}
-	} else if msg.SourceUUID != "" {
-		if err := b.SignalClient.SendFileWithQuote(msg.SourceUUID, filePath, caption, quote); err != nil {
-			log.Printf("Error sending file to user-uuid: %v", err)
+		sb.WriteString(fmt.Sprintf("\n\nAttached file %s:\n%s", name, text))
+	}
+	return sb.String()
+}
+
+// readAttachment downloads an attachment, honouring MaxAttachmentSize, and returns its content
+func (b *Bot) readAttachment(ctx context.Context, a signal.Attachment) ([]byte, error) {
+	if b.MaxAttachmentSize > 0 && a.Size > b.MaxAttachmentSize {
+		return nil, fmt.Errorf("attachment is %d bytes, limit is %d", a.Size, b.MaxAttachmentSize)
+	}
+	path, err := b.SignalClient.GetAttachment(ctx, a.ID, b.MaxAttachmentSize)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	return os.ReadFile(path)
+}
+
+// sendReceipt marks msg as read, or as viewed when it carries media, unless
+// receipts are disabled. Messages from the bot's own devices get none.
+func (b *Bot) sendReceipt(ctx context.Context, msg message.Message) {
+	if !b.SendReceipts || msg.Sync || msg.RawEvent == nil || msg.RawEvent.Timestamp == 0 {
+		return
+	}
+	sender := authorOf(msg)
+	if sender == "" {
+		return
+	}
+	// Withheld view-once media was not viewed, so it only gets a read receipt.
+	receiptType := signal.ReceiptRead
+	if len(b.attachmentsOf(msg)) > 0 {
+		receiptType = signal.ReceiptViewed
+	}
+	if err := b.SignalClient.SendReceipt(ctx, sender, receiptType, msg.RawEvent.Timestamp); err != nil {
+		log.Printf("Error sending %s receipt to %s: %v", receiptType, sender, err)
+	}
+}
+
+// react adds emoji as the bot's reaction to msg; an empty emoji is a no-op.
+// Signal keeps one reaction per sender, so each call replaces the previous one.
+func (b *Bot) react(ctx context.Context, msg message.Message, emoji string) {
+	if emoji == "" || msg.Timestamp == 0 {
+		return
+	}
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient for reaction: %v", err)
+		return
+	}
+	if err := b.SignalClient.SendReaction(ctx, recipient, emoji, authorOf(msg), msg.Timestamp); err != nil {
+		log.Printf("Error sending reaction to %s: %v", message.TargetLabel(msg), err)
+	}
+}
+
+// resolveRecipient returns the address replies to msg should be sent to: the
+// public group ID for group messages, otherwise the sender's number or UUID
+func (b *Bot) resolveRecipient(ctx context.Context, msg message.Message) (string, error) {
+	if msg.GroupID != "" {
+		publicID, err := b.Groups.PublicID(ctx, msg.GroupID)
+		if err != nil {
+			return "", fmt.Errorf("get public group id: %w", err)
+		}
+		return publicID, nil
+	}
+	if msg.Sync && msg.Destination != "" {
+		return msg.Destination, nil
+	}
+	if msg.SourceNumber != "" {
+		return msg.SourceNumber, nil
+	}
+	if msg.SourceUUID != "" {
+		return msg.SourceUUID, nil
+	}
+	return "", fmt.Errorf("message has no group or sender")
+}
+
+// showTyping displays a typing indicator in the chat for msg, refreshing it
+// until the returned stop function is called or ctx is cancelled
+func (b *Bot) showTyping(ctx context.Context, msg message.Message) (stop func()) {
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient for typing indicator: %v", err)
+		return func() {}
+	}
+
+	typingCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(typingRefreshInterval)
+		defer ticker.Stop()
+		for {
+			if err := b.SignalClient.StartTyping(typingCtx, recipient); err != nil && typingCtx.Err() == nil {
+				log.Printf("Error starting typing indicator: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-typingCtx.Done():
+				// Clear the indicator even when ctx itself was cancelled.
+				if err := b.SignalClient.StopTyping(context.WithoutCancel(ctx), recipient); err != nil {
+					log.Printf("Error stopping typing indicator: %v", err)
+				}
+				return
+			}
 		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
 	}
 }
 
 // handleInstagramDownload processes Instagram video download requests
-func (b *Bot) handleInstagramDownload(msg message.Message, instagramURL string) {
-	log.Printf("Processing Instagram download request for: %s", instagramURL)
+func (b *Bot) handleInstagramDownload(ctx context.Context, msg message.Message, instagramURL string) {
+	log.Printf("Processing Instagram download request for: %s", loggable(msg, instagramURL))
+	b.react(ctx, msg, b.Reactions.Accepted)
+	progress, _ := b.sendResponse(ctx, msg, "⏳ Downloading Instagram video...")
 
-	result := igdownloader.DownloadInstagramVideo(instagramURL)
+	result := igdownloader.DownloadInstagramVideo(instagramURL, msg.ExpiresInSeconds > 0)
 
 	if !result.Success {
-		log.Printf("Instagram download failed: %v", result.Error)
-		b.sendResponse(msg, "Failed to download Instagram video. Please check the URL and try again.")
+		// Download errors can name the post's URLs.
+		log.Printf("Instagram download failed: %s", loggable(msg, fmt.Sprint(result.Error)))
+		b.react(ctx, msg, b.Reactions.Failed)
+		b.updateResponse(ctx, msg, progress, "Failed to download Instagram video. Please check the URL and try again.")
+		return
+	}
+
+	if progress != 0 {
+		b.editResponse(ctx, msg, progress, "📤 Uploading Instagram video...")
+	}
+	if msg.ExpiresInSeconds > 0 {
+		// Don't keep media from a disappearing chat around after sending it.
+		defer os.Remove(result.VideoFile)
+	}
+	if _, ok := b.sendFile(ctx, msg, result.VideoFile, ""); !ok {
+		b.react(ctx, msg, b.Reactions.Failed)
+		b.updateResponse(ctx, msg, progress, "Downloaded the Instagram video but failed to send it.")
+		return
+	}
+	if progress != 0 {
+		b.editResponse(ctx, msg, progress, "✅ Instagram video downloaded.")
+	}
+	b.react(ctx, msg, b.Reactions.Succeeded)
+}
+
+// handleDeleteCommand deletes the bot message that msg replies to for everyone.
+// Only the person the message answered or a configured admin may delete it.
+func (b *Bot) handleDeleteCommand(ctx context.Context, msg message.Message) {
+	if msg.Quote == nil || !b.isSelf(msg.Quote.Author) {
+		b.sendResponse(ctx, msg, "To delete one of my messages, reply to it with '@bot /delete'")
 		return
 	}
 
-	b.sendFile(msg, result.VideoFile, "")
+	asker, known := b.replies.Asker(msg.Quote.ID)
+	if !b.isAdmin(msg) && (!known || asker != authorOf(msg)) {
+		log.Printf("Refusing delete of %d requested by %s", msg.Quote.ID, authorOf(msg))
+		b.sendResponse(ctx, msg, "Only the person who asked or an admin can delete that message.")
+		return
+	}
+
+	recipient, err := b.resolveRecipient(ctx, msg)
+	if err != nil {
+		log.Printf("Error resolving recipient: %v", err)
+		return
+	}
+	if err := b.SignalClient.RemoteDelete(ctx, recipient, msg.Quote.ID); err != nil {
+		log.Printf("Error deleting message %d in %s: %v", msg.Quote.ID, message.TargetLabel(msg), err)
+		b.sendErrorResponse(ctx, msg)
+		return
+	}
+	b.react(ctx, msg, b.Reactions.Succeeded)
+}
+
+// handleProfileCommand lets admins change the bot's Signal profile with
+// "/profile name <name>", "/profile about <text>" or "/profile avatar" sent
+// with an image attached
+func (b *Bot) handleProfileCommand(ctx context.Context, msg message.Message) {
+	if !b.isAdmin(msg) {
+		log.Printf("Refusing profile change requested by %s", authorOf(msg))
+		b.sendResponse(ctx, msg, "Only an admin can change my profile.")
+		return
+	}
+
+	args := strings.TrimSpace(strings.TrimSpace(msg.CleanText)[len("/profile"):])
+	field, value, _ := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	profile := signal.Profile{Name: b.Profile.Name, About: b.Profile.About}
+	switch strings.ToLower(field) {
+	case "name":
+		profile.Name = value
+	case "about":
+		profile.About = value
+	case "avatar":
+		for _, a := range b.attachmentsOf(msg) {
+			if !strings.HasPrefix(a.ContentType, "image/") {
+				continue
+			}
+			path, err := b.SignalClient.GetAttachment(ctx, a.ID, b.MaxAttachmentSize)
+			if err != nil {
+				log.Printf("Error downloading avatar %s: %v", a.ID, err)
+				b.sendErrorResponse(ctx, msg)
+				return
+			}
+			defer os.Remove(path)
+			profile.AvatarPath = path
+			break
+		}
+		if profile.AvatarPath == "" {
+			b.sendResponse(ctx, msg, "Attach the new avatar image to '@bot /profile avatar'.")
+			return
+		}
+	default:
+		b.sendResponse(ctx, msg, fmt.Sprintf("Current profile: name %q, about %q.\nChange it with '@bot /profile name <name>', '@bot /profile about <text>' or '@bot /profile avatar' with an image attached.", b.Profile.Name, b.Profile.About))
+		return
+	}
+	if profile.Name == "" {
+		b.sendResponse(ctx, msg, "A profile name is required; set one with '@bot /profile name <name>'.")
+		return
+	}
+
+	if err := b.SignalClient.UpdateProfile(ctx, profile); err != nil {
+		log.Printf("Error updating profile of %s: %v", b.label(), err)
+		b.react(ctx, msg, b.Reactions.Failed)
+		b.sendErrorResponse(ctx, msg)
+		return
+	}
+	b.Profile.Name, b.Profile.About = profile.Name, profile.About
+	b.react(ctx, msg, b.Reactions.Succeeded)
+}
+
+// isSelf reports whether addr (a number or UUID) belongs to the bot account
+func (b *Bot) isSelf(addr string) bool {
+	if addr == "" {
+		return false
+	}
+	if b.BotUUID != "" && addr == b.BotUUID {
+		return true
+	}
+	return message.NormalizePhone(addr) == message.NormalizePhone(b.BotNumber)
+}
+
+// commands lists the commands the bot handles itself rather than passing to the LLM
+var commands = []string{"/help", "/delete", "/trust", "/profile", "/download"}
+
+// commandName returns the command text starts with, lowercased, or "" when
+// its first word is not exactly one of commands
+func commandName(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.ToLower(fields[0])
+	if slices.Contains(commands, name) {
+		return name
+	}
+	return ""
+}
+
+// quotesBot reports whether msg replies to a message the bot sent
+func (b *Bot) quotesBot(msg message.Message) bool {
+	return msg.Quote != nil && b.isSelf(msg.Quote.Author)
+}
+
+// isNoteToSelf reports whether msg was written to the bot's own Note to Self
+// chat from a linked device
+func (b *Bot) isNoteToSelf(msg message.Message) bool {
+	return msg.Sync && msg.GroupID == "" && b.isSelf(msg.Destination)
+}
+
+// isOwnOutput reports whether msg was written by the bot rather than a person:
+// anything the bot itself sent, and messages from its own account that did
+// not come from a linked device
+func (b *Bot) isOwnOutput(msg message.Message) bool {
+	if !b.isSelf(msg.SourceNumber) && !b.isSelf(msg.SourceUUID) {
+		return false
+	}
+	if !msg.Sync {
+		return true
+	}
+	_, sentByBot := b.replies.Asker(msg.Timestamp)
+	return sentByBot
+}
+
+// isAdmin reports whether the sender of msg is a configured admin. The
+// operator writing from a linked device of the bot's account always is.
+func (b *Bot) isAdmin(msg message.Message) bool {
+	if msg.Sync && (b.isSelf(msg.SourceNumber) || b.isSelf(msg.SourceUUID)) {
+		return true
+	}
+	for _, admin := range b.Admins {
+		admin = message.NormalizePhone(admin)
+		if admin == "" {
+			continue
+		}
+		if admin == message.NormalizePhone(msg.SourceNumber) || admin == msg.SourceUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// label names the bot's account in logs
+func (b *Bot) label() string {
+	if b.Name != "" {
+		return b.Name + " (" + b.BotNumber + ")"
+	}
+	return b.BotNumber
 }
 
 // handleHelpCommand sends a help message with all available commands
-func (b *Bot) handleHelpCommand(msg message.Message) {
-	helpText := `🤖 *Signal Bot Commands*
+func (b *Bot) handleHelpCommand(ctx context.Context, msg message.Message) {
+	title := "Signal Bot"
+	if b.Name != "" {
+		title = b.Name
+	}
+	helpText := "🤖 **" + title + ` Commands**
 
-*Available Commands:*
+**Available Commands:**
 • /download - Download an Instagram video
   • Reply to a message containing an Instagram URL with '@bot /download'
   • Or use '@bot /download <instagram_url>'
 
+• /delete - Delete one of the bot's replies for everyone
+  • Reply to the bot's message with '@bot /delete'
+  • Only the person who asked or an admin can delete a reply
+
+• /profile - Change the bot's name, about text or avatar (admins only)
+  • '@bot /profile name <name>' or '@bot /profile about <text>'
+  • '@bot /profile avatar' with an image attached
+
+• /trust - Trust a contact's changed safety number (admins only)
+  • '@bot /trust <number>' or '@bot /trust <number> <safety number>'
+
 • /help - Show this help message
 
-*General Usage:*
+**General Usage:**
 • Mention @bot in any message to chat with the AI
+• Reply to one of the bot's messages with a sticker to send it the sticker
 • The bot responds to your questions and conversations
 • When you reply to a message, the bot includes that context in its response
 `
-	b.sendResponse(msg, helpText)
+	b.sendResponse(ctx, msg, helpText)
 }
@@ -0,0 +1,665 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/afeedhshaji/signal-llm-bot/internal/bot/message"
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal/signaltest"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/deduper"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/linkpreview"
+)
+
+const (
+	testBotNumber = "+1234567890"
+	testUser      = "+9876543210"
+)
+
+type stubLLM struct {
+	answer  string
+	err     error
+	prompts []string
+	onAsk   func() // called while Ask runs, when set
+}
+
+func (s *stubLLM) Ask(prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if s.onAsk != nil {
+		s.onAsk()
+	}
+	return s.answer, s.err
+}
+
+func newTestBot(t *testing.T, llm *stubLLM) (*Bot, *signaltest.Transport) {
+	t.Helper()
+	transport := signaltest.New()
+	dedup := deduper.New(time.Minute)
+	t.Cleanup(dedup.Stop)
+	b := NewBot(transport, llm, time.Second, dedup, testBotNumber)
+	b.IgnoreSelf = true
+	return b, transport
+}
+
+// mentionEnvelope builds an envelope from testUser that mentions the bot
+func mentionEnvelope(ts int64, text string, group string) signal.Envelope {
+	dm := &signal.DataMessage{
+		Message:  "@bot " + text,
+		Mentions: []signal.Mention{{Start: 0, Length: 4, Number: testBotNumber}},
+	}
+	if group != "" {
+		dm.GroupInfo = &signal.GroupInfo{GroupID: group}
+	}
+	return signal.Envelope{SourceNumber: testUser, SourceUUID: "user-uuid", Timestamp: ts, DataMessage: dm}
+}
+
+func TestHandleEvent_DirectMessage(t *testing.T) {
+	llm := &stubLLM{answer: "4"}
+	b, transport := newTestBot(t, llm)
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "what is 2+2?", ""))
+
+	if len(llm.prompts) != 1 || llm.prompts[0] != "what is 2+2?" {
+		t.Fatalf("unexpected prompts %q", llm.prompts)
+	}
+	sent := transport.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sent))
+	}
+	if sent[0].To != testUser || sent[0].Message.Text != "4" {
+		t.Errorf("unexpected reply %+v", sent[0])
+	}
+	if q := sent[0].Message.Quote; q == nil || q.ID != 100 || q.Author != testUser {
+		t.Errorf("expected reply to quote the request, got %+v", q)
+	}
+	if r := transport.Receipts(); len(r) != 0 {
+		t.Errorf("expected no receipts when disabled, got %+v", r)
+	}
+}
+
+func TestHandleEvent_GroupMentionsAsker(t *testing.T) {
+	llm := &stubLLM{answer: "hello"}
+	b, transport := newTestBot(t, llm)
+	b.MentionAsker = true
+	transport.Groups = []signal.Group{{ID: "group.public", InternalID: "internal"}}
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hi", "internal"))
+
+	sent := transport.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sent))
+	}
+	if sent[0].To != "group.public" {
+		t.Errorf("expected reply to the public group id, got %q", sent[0].To)
+	}
+	mentions := sent[0].Message.Mentions
+	if len(mentions) != 1 || mentions[0].Author != "user-uuid" || mentions[0].Start != 0 {
+		t.Errorf("unexpected mentions %+v", mentions)
+	}
+	if !strings.HasPrefix(sent[0].Message.Text, signal.MentionPlaceholder) {
+		t.Errorf("expected text to start with the mention, got %q", sent[0].Message.Text)
+	}
+}
+
+func TestHandleEvent_IgnoresUnmentioned(t *testing.T) {
+	llm := &stubLLM{answer: "unused"}
+	b, transport := newTestBot(t, llm)
+
+	b.handleEvent(context.Background(), signal.Envelope{
+		SourceNumber: testUser,
+		Timestamp:    100,
+		DataMessage:  &signal.DataMessage{Message: "just chatting"},
+	})
+
+	if len(llm.prompts) != 0 || len(transport.Sent()) != 0 {
+		t.Error("expected unmentioned message to be ignored")
+	}
+}
+
+func TestHandleEvent_IgnoresOwnOutput(t *testing.T) {
+	llm := &stubLLM{answer: "unused"}
+	b, transport := newTestBot(t, llm)
+
+	ev := mentionEnvelope(100, "echo", "")
+	ev.SourceNumber = testBotNumber
+	b.handleEvent(context.Background(), ev)
+
+	if len(llm.prompts) != 0 || len(transport.Sent()) != 0 {
+		t.Error("expected the bot's own message to be ignored")
+	}
+}
+
+func TestHandleEvent_LLMErrorReactsAndReplies(t *testing.T) {
+	llm := &stubLLM{err: errors.New("timeout")}
+	b, transport := newTestBot(t, llm)
+	b.Reactions = Reactions{Accepted: "👀", Succeeded: "✅", Failed: "❌"}
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "question", ""))
+
+	reactions := transport.Reactions()
+	if len(reactions) != 2 || reactions[0].Emoji != "👀" || reactions[1].Emoji != "❌" {
+		t.Errorf("unexpected reactions %+v", reactions)
+	}
+	sent := transport.Sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Message.Text, "error occurred") {
+		t.Errorf("expected a generic error reply, got %+v", sent)
+	}
+}
+
+func TestHandleEvent_FailedReplyReactsFailed(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "lost"})
+	b.Reactions = Reactions{Accepted: "👀", Succeeded: "✅", Failed: "❌"}
+	transport.SendErr = &signal.RateLimitError{RetryAfter: time.Minute, Response: &signal.APIError{Method: "POST", Path: "/v2/send", StatusCode: 413}}
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "question", ""))
+
+	reactions := transport.Reactions()
+	if len(reactions) != 2 || reactions[0].Emoji != "👀" || reactions[1].Emoji != "❌" {
+		t.Errorf("expected ❌ for an undelivered answer, got %+v", reactions)
+	}
+}
+
+func TestHandleEvent_DeleteOwnReply(t *testing.T) {
+	llm := &stubLLM{answer: "oops"}
+	b, transport := newTestBot(t, llm)
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "question", ""))
+	replyTS := transport.Sent()[0].Timestamp
+
+	del := mentionEnvelope(200, "/delete", "")
+	del.DataMessage.Quote = &signal.Quote{ID: replyTS, Author: testBotNumber, Text: "oops"}
+	b.handleEvent(context.Background(), del)
+
+	if deleted := transport.Deleted(); len(deleted) != 1 || deleted[0] != replyTS {
+		t.Errorf("expected reply %d to be deleted, got %v", replyTS, deleted)
+	}
+}
+
+func TestHandleEvent_DeleteCaptionlessFile(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{})
+	asker := message.Message{SourceNumber: testUser, SourceUUID: "user-uuid", Timestamp: 100}
+	fileTS, _ := b.sendFile(context.Background(), asker, "clip.mp4", "")
+
+	del := mentionEnvelope(200, "/delete", "")
+	del.DataMessage.Quote = &signal.Quote{ID: fileTS, Author: testBotNumber}
+	b.handleEvent(context.Background(), del)
+
+	if deleted := transport.Deleted(); len(deleted) != 1 || deleted[0] != fileTS {
+		t.Errorf("expected file %d to be deleted, got %v", fileTS, deleted)
+	}
+}
+
+func TestStart_WebSocketMode(t *testing.T) {
+	llm := &stubLLM{answer: "streamed"}
+	b, transport := newTestBot(t, llm)
+	b.ReceiveMode = ReceiveModeWebSocket
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+
+	transport.Inject(mentionEnvelope(100, "hello", ""))
+	deadline := time.After(2 * time.Second)
+	for len(transport.Sent()) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for reply")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestStart_DiscoversUUIDForMentions(t *testing.T) {
+	llm := &stubLLM{answer: "found you"}
+	b, transport := newTestBot(t, llm)
+	b.ReceiveMode = ReceiveModeWebSocket
+	transport.UUID = "bot-uuid"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+
+	transport.Inject(signal.Envelope{SourceNumber: testUser, Timestamp: 100, DataMessage: &signal.DataMessage{
+		Message:  signal.MentionPlaceholder + " hi",
+		Mentions: []signal.Mention{{Start: 0, Length: 1, UUID: "bot-uuid"}},
+	}})
+	deadline := time.After(2 * time.Second)
+	for len(transport.Sent()) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for reply to UUID mention")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestHandleEvent_ProfileCommandRequiresAdmin(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{})
+	b.Profile = signal.Profile{Name: "Bot"}
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "/profile about Ask me anything", ""))
+	if p := transport.Profiles(); len(p) != 0 {
+		t.Fatalf("non-admin changed the profile: %+v", p)
+	}
+
+	b.Admins = []string{testUser}
+	b.handleEvent(context.Background(), mentionEnvelope(101, "/profile about Ask me anything", ""))
+	p := transport.Profiles()
+	if len(p) != 1 || p[0].Name != "Bot" || p[0].About != "Ask me anything" {
+		t.Fatalf("unexpected profile updates %+v", p)
+	}
+	if b.Profile.About != "Ask me anything" {
+		t.Errorf("bot profile not updated: %+v", b.Profile)
+	}
+}
+
+func TestHandleEvent_TrustUnverifiedRetriesSend(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "hi again"})
+	b.TrustPolicy = TrustUnverified
+	transport.Untrusted[testUser] = true
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hello", ""))
+
+	if trusted := transport.Trusted(); len(trusted) != 1 || trusted[0] != testUser {
+		t.Fatalf("expected %s to be trusted, got %v", testUser, trusted)
+	}
+	if sent := transport.Sent(); len(sent) != 1 || sent[0].To != testUser {
+		t.Fatalf("expected the reply to be resent, got %+v", sent)
+	}
+}
+
+func TestHandleEvent_TrustUnverifiedKeepsVerifiedKeys(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "hi again"})
+	b.TrustPolicy = TrustUnverified
+	// Before the change the contact's key was verified; afterwards only the
+	// new, untrusted key is listed.
+	transport.Identities = []signal.Identity{{Number: testUser, Status: signal.TrustTrustedVerified}}
+	b.loadVerified(context.Background())
+	transport.Identities = []signal.Identity{{Number: testUser, Status: signal.TrustUntrusted}}
+	transport.Untrusted[testUser] = true
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hello", ""))
+
+	if trusted := transport.Trusted(); len(trusted) != 0 {
+		t.Fatalf("new key of a verified contact was trusted automatically: %v", trusted)
+	}
+}
+
+func TestHandleEvent_TrustedGroupSendNotRepeated(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "hi all"})
+	b.TrustPolicy = TrustAlways
+	transport.Groups = []signal.Group{{ID: "group.public", InternalID: "internal"}}
+	transport.Untrusted["group.public"] = true
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hello", "internal"))
+
+	if trusted := transport.Trusted(); len(trusted) != 1 {
+		t.Fatalf("expected the new key to be trusted, got %v", trusted)
+	}
+	if sent := transport.Sent(); len(sent) != 0 {
+		t.Fatalf("group reply was sent again after trusting: %+v", sent)
+	}
+}
+
+func TestHandleEvent_TrustOnFirstUseNotifiesAdminsOnce(t *testing.T) {
+	const admin = "+1111111111"
+	b, transport := newTestBot(t, &stubLLM{answer: "hi again"})
+	b.Admins = []string{admin}
+	transport.Untrusted[testUser] = true
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hello", ""))
+	b.handleEvent(context.Background(), mentionEnvelope(101, "hello?", ""))
+
+	if trusted := transport.Trusted(); len(trusted) != 0 {
+		t.Fatalf("changed key was trusted without an admin: %v", trusted)
+	}
+	sent := transport.Sent()
+	if len(sent) != 1 || sent[0].To != admin || !strings.Contains(sent[0].Message.Text, "/trust "+testUser) {
+		t.Fatalf("expected one notice to the admin, got %+v", sent)
+	}
+}
+
+func TestHandleEvent_DisappearingReplies(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	link := srv.URL + "/hidden-page"
+	b, transport := newTestBot(t, &stubLLM{answer: "shh, see " + link})
+	b.LinkPreviews = linkpreview.New(srv.Client())
+	logs := captureLog(t)
+	ev := mentionEnvelope(100, "secret", "")
+	ev.DataMessage.ExpiresInSeconds = 60
+
+	b.handleEvent(context.Background(), ev)
+
+	sent := transport.Sent()
+	if len(sent) != 1 || sent[0].Message.ExpiresInSeconds != 60 {
+		t.Fatalf("expected reply to expire with the chat, got %+v", sent)
+	}
+	if strings.Contains(logs.String(), "secret") || strings.Contains(logs.String(), "hidden-page") {
+		t.Errorf("content of a disappearing chat was logged:\n%s", logs)
+	}
+}
+
+// captureLog redirects the standard logger to a buffer for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestReplyLog_ForgetsExpiredReplies(t *testing.T) {
+	r := newReplyLog()
+	key := replyKey{Author: testUser, Timestamp: 100}
+	r.Record(key, 200, time.Millisecond)
+	r.Record(replyKey{Author: testUser, Timestamp: 101}, 201, 0)
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := r.Lookup(key); ok {
+		t.Error("expired reply still recorded")
+	}
+	if _, ok := r.Asker(200); ok {
+		t.Error("expired asker still recorded")
+	}
+	if _, ok := r.Asker(201); !ok {
+		t.Error("reply without a timer was forgotten")
+	}
+}
+
+func TestHandleEvent_WithholdsViewOnceAttachments(t *testing.T) {
+	llm := &stubLLM{answer: "ok"}
+	b, transport := newTestBot(t, llm)
+	transport.Attachments["att1"] = []byte("private notes")
+	ev := mentionEnvelope(100, "summarise", "")
+	ev.DataMessage.ViewOnce = true
+	ev.DataMessage.Attachments = []signal.Attachment{{ID: "att1", ContentType: "text/plain", Filename: "notes.txt"}}
+
+	b.handleEvent(context.Background(), ev)
+	if len(llm.prompts) != 1 || strings.Contains(llm.prompts[0], "private notes") || !strings.Contains(llm.prompts[0], "view-once") {
+		t.Fatalf("view-once content reached the LLM: %q", llm.prompts)
+	}
+
+	b.AllowViewOnce = true
+	ev.Timestamp = 101
+	b.handleEvent(context.Background(), ev)
+	if len(llm.prompts) != 2 || !strings.Contains(llm.prompts[1], "private notes") {
+		t.Fatalf("allowed view-once content missing from prompt: %q", llm.prompts)
+	}
+}
+
+func TestSendFile_ViewOnceGroups(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{})
+	transport.Groups = []signal.Group{{ID: "group.public", InternalID: "internal"}}
+	b.ViewOnceGroups = []string{"group.public"}
+	msg := message.Message{SourceNumber: testUser, GroupID: "internal", Timestamp: 100}
+
+	b.sendFile(context.Background(), msg, "clip.mp4", "")
+	b.sendFile(context.Background(), msg, "reply.md", "")
+	msg.GroupID = ""
+	b.sendFile(context.Background(), msg, "clip.mp4", "")
+
+	sent := transport.Sent()
+	if len(sent) != 3 || !sent[0].Message.ViewOnce || sent[1].Message.ViewOnce || sent[2].Message.ViewOnce {
+		t.Fatalf("expected only the video in the listed group to be view-once, got %+v", sent)
+	}
+}
+
+func TestHandleEvent_DescribesStickers(t *testing.T) {
+	llm := &stubLLM{answer: "haha"}
+	b, _ := newTestBot(t, llm)
+	sticker := func(ts int64, group string, quote *signal.Quote) signal.Envelope {
+		dm := &signal.DataMessage{Sticker: &signal.Sticker{PackID: "pack", StickerID: 3, Emoji: "😂"}, Quote: quote}
+		if group != "" {
+			dm.GroupInfo = &signal.GroupInfo{GroupID: group}
+		}
+		return signal.Envelope{SourceNumber: testUser, Timestamp: ts, DataMessage: dm}
+	}
+
+	b.handleEvent(context.Background(), sticker(100, "", nil))
+	b.handleEvent(context.Background(), sticker(101, "group", nil))
+	if len(llm.prompts) != 0 {
+		t.Fatalf("sticker not addressed to the bot reached the LLM: %q", llm.prompts)
+	}
+
+	joke := &signal.Quote{ID: 50, Author: testBotNumber, Text: "a joke"}
+	b.handleEvent(context.Background(), sticker(102, "", joke))
+	if len(llm.prompts) != 1 || !strings.HasSuffix(llm.prompts[0], "[User sent a 😂 sticker]") {
+		t.Fatalf("expected a direct sticker replying to the bot to reach the LLM, got %q", llm.prompts)
+	}
+
+	b.handleEvent(context.Background(), sticker(103, "group", joke))
+	if len(llm.prompts) != 2 || !strings.Contains(llm.prompts[1], "😂 sticker") {
+		t.Fatalf("expected a group sticker replying to the bot to reach the LLM, got %q", llm.prompts)
+	}
+}
+
+func TestHandleEvent_LinkPreviews(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			fmt.Fprint(w, `<html><head><title>Fallback</title>
+<meta property="og:title" content="Go &amp; Signal">
+<meta property="og:description" content="How the bot talks to Signal">
+<meta property="og:image" content="/thumb.png"></head></html>`)
+		case "/thumb.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	llm := &stubLLM{answer: "Source: " + srv.URL + "/article."}
+	b, transport := newTestBot(t, llm)
+	b.handleEvent(context.Background(), mentionEnvelope(100, "cite it", ""))
+
+	b.LinkPreviews = linkpreview.New(srv.Client())
+	b.handleEvent(context.Background(), mentionEnvelope(101, "cite it", ""))
+
+	sent := transport.Sent()
+	if len(sent) != 2 || sent[0].Message.LinkPreview != nil {
+		t.Fatalf("expected no preview while disabled, got %+v", sent)
+	}
+	p := sent[1].Message.LinkPreview
+	if p == nil || p.URL != srv.URL+"/article" || p.Title != "Go & Signal" ||
+		p.Description != "How the bot talks to Signal" || string(p.Thumbnail) != string(png) {
+		t.Fatalf("unexpected preview %+v", p)
+	}
+}
+
+// syncEnvelope builds a message the operator sent from a linked device of the
+// bot's account to destination
+func syncEnvelope(ts int64, text, destination string) signal.Envelope {
+	return signal.Envelope{SourceNumber: testBotNumber, Timestamp: ts, SyncMessage: &signal.SyncMessage{
+		SentMessage: &signal.SentMessage{
+			DestinationNumber: destination,
+			Timestamp:         ts,
+			DataMessage:       signal.DataMessage{Message: text},
+		},
+	}}
+}
+
+func TestHandleEvent_SyncToOthersNeverReachesLLM(t *testing.T) {
+	llm := &stubLLM{answer: "nope"}
+	b, transport := newTestBot(t, llm)
+
+	b.handleEvent(context.Background(), syncEnvelope(100, "/foo", testUser))
+	b.handleEvent(context.Background(), syncEnvelope(101, "/etc/hosts is broken", testUser))
+	b.handleEvent(context.Background(), syncEnvelope(102, "/helpful tip", testUser))
+
+	if len(llm.prompts) != 0 || len(transport.Sent()) != 0 {
+		t.Fatalf("operator's message to another chat was answered: prompts=%q sent=%+v", llm.prompts, transport.Sent())
+	}
+
+	b.handleEvent(context.Background(), syncEnvelope(103, "/foo", testBotNumber))
+	if len(llm.prompts) != 1 || llm.prompts[0] != "/foo" {
+		t.Fatalf("expected Note to Self to reach the LLM, got %q", llm.prompts)
+	}
+}
+
+func TestSendResponse_UnknownTimestampCountsAsSent(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{})
+	transport.NoTimestamps = true
+	b.MaxMessageLength = 40
+	msg := message.Message{SourceNumber: testUser, Timestamp: 100}
+
+	_, ok := b.sendResponse(context.Background(), msg, strings.Repeat("word ", 20))
+
+	if !ok || len(transport.Sent()) < 3 {
+		t.Fatalf("expected every part to be sent, got ok=%v sent=%d", ok, len(transport.Sent()))
+	}
+}
+
+func TestHandleEvent_TruncatesLongTextAttachments(t *testing.T) {
+	llm := &stubLLM{answer: "ok"}
+	b, transport := newTestBot(t, llm)
+	transport.Attachments["log"] = []byte(strings.Repeat("x", 100<<10))
+	ev := mentionEnvelope(100, "what went wrong?", "")
+	ev.DataMessage.Attachments = []signal.Attachment{{ID: "log", ContentType: "text/plain", Filename: "app.log", Size: 100 << 10}}
+
+	b.handleEvent(context.Background(), ev)
+
+	if len(llm.prompts) != 1 || len(llm.prompts[0]) > maxInlineText+500 || !strings.Contains(llm.prompts[0], "Truncated") {
+		t.Fatalf("expected a truncated attachment in the prompt, got %d bytes", len(llm.prompts[0]))
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after two seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for !cond() {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s", what)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestHandleEvent_TypingWhileAsking(t *testing.T) {
+	for name, askErr := range map[string]error{"success": nil, "error": errors.New("timeout")} {
+		t.Run(name, func(t *testing.T) {
+			llm := &stubLLM{answer: "done", err: askErr}
+			b, transport := newTestBot(t, llm)
+			llm.onAsk = func() {
+				waitFor(t, "typing indicator", func() bool { return transport.Typing(testUser) })
+			}
+
+			b.handleEvent(context.Background(), mentionEnvelope(100, "think hard", ""))
+
+			if len(llm.prompts) != 1 {
+				t.Fatalf("expected one question, got %q", llm.prompts)
+			}
+			if transport.Typing(testUser) {
+				t.Error("typing indicator still shown after the reply")
+			}
+		})
+	}
+}
+
+func TestShowTyping_StopsWhenCancelled(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{})
+	ctx, cancel := context.WithCancel(context.Background())
+	msg := message.Message{SourceNumber: testUser, Timestamp: 100}
+
+	stop := b.showTyping(ctx, msg)
+	waitFor(t, "typing indicator", func() bool { return transport.Typing(testUser) })
+	cancel()
+	waitFor(t, "typing indicator to clear", func() bool { return !transport.Typing(testUser) })
+	stop()
+}
+
+// editEnvelope builds an edit by testUser of the message sent at target
+func editEnvelope(ts, target int64, text string) signal.Envelope {
+	ev := mentionEnvelope(ts, text, "")
+	ev.EditMessage = &signal.EditMessage{TargetSentTimestamp: target, DataMessage: ev.DataMessage}
+	ev.DataMessage = nil
+	return ev
+}
+
+func TestHandleEvent_EditRevisesReply(t *testing.T) {
+	llm := &stubLLM{answer: "Paris"}
+	b, transport := newTestBot(t, llm)
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "capital of France?", ""))
+	llm.answer = "Rome"
+	b.handleEvent(context.Background(), editEnvelope(200, 100, "capital of Italy?"))
+
+	sent := transport.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected the reply and its edit, got %+v", sent)
+	}
+	if edit := sent[1].Message; edit.EditTimestamp != sent[0].Timestamp || edit.Text != "Rome" {
+		t.Errorf("expected reply %d to be edited to %q, got %+v", sent[0].Timestamp, "Rome", edit)
+	}
+	if llm.prompts[1] != "capital of Italy?" {
+		t.Errorf("edited question not asked, got %q", llm.prompts)
+	}
+}
+
+func TestHandleEvent_EditWithoutReplySendsNew(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "Rome"})
+
+	b.handleEvent(context.Background(), editEnvelope(200, 100, "capital of Italy?"))
+
+	sent := transport.Sent()
+	if len(sent) != 1 || sent[0].Message.EditTimestamp != 0 {
+		t.Fatalf("expected a new message, got %+v", sent)
+	}
+	if q := sent[0].Message.Quote; q == nil || q.ID != 100 || q.Author != testUser {
+		t.Errorf("expected the new reply to quote the original message, got %+v", q)
+	}
+}
+
+func TestHandleEvent_SendsReceipts(t *testing.T) {
+	b, transport := newTestBot(t, &stubLLM{answer: "ok"})
+	b.SendReceipts = true
+	photo := []signal.Attachment{{ID: "img", ContentType: "image/jpeg", Filename: "photo.jpg"}}
+
+	b.handleEvent(context.Background(), mentionEnvelope(100, "hello", ""))
+	media := mentionEnvelope(101, "what is this?", "")
+	media.DataMessage.Attachments = photo
+	b.handleEvent(context.Background(), media)
+	viewOnce := mentionEnvelope(102, "and this?", "")
+	viewOnce.DataMessage.Attachments = photo
+	viewOnce.DataMessage.ViewOnce = true
+	b.handleEvent(context.Background(), viewOnce)
+
+	want := []signaltest.Receipt{
+		{To: testUser, Type: signal.ReceiptRead, Timestamp: 100},
+		{To: testUser, Type: signal.ReceiptViewed, Timestamp: 101},
+		{To: testUser, Type: signal.ReceiptRead, Timestamp: 102},
+	}
+	got := transport.Receipts()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d receipts, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("receipt %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package format converts the Markdown produced by LLMs into text Signal can display.
+package format
+
+import (
+	"regexp"
+	"strings"
+)
+
+// styles renders each inline style around already converted content
+type styles struct {
+	bold, italic, strike, code func(string) string
+}
+
+// signalStyles produces signal-cli's styled text syntax (text_mode "styled")
+var signalStyles = styles{
+	bold:   func(s string) string { return "**" + s + "**" },
+	italic: func(s string) string { return "*" + s + "*" },
+	strike: func(s string) string { return "~" + s + "~" },
+	code:   func(s string) string { return "`" + s + "`" },
+}
+
+// plainStyles drops all markup and keeps the content
+var plainStyles = styles{
+	bold:   func(s string) string { return s },
+	italic: func(s string) string { return s },
+	strike: func(s string) string { return s },
+	code:   func(s string) string { return s },
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	bulletRe   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	orderedRe  = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	fenceRe    = regexp.MustCompile("^\\s*(```|~~~)")
+	hruleRe    = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	blockquote = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+)
+
+// Styled converts Markdown (bold, italic, strikethrough, inline code, code
+// blocks, headings and lists) into signal-cli's styled text syntax, to be sent
+// with text_mode "styled", and reports true. Styled text cannot escape its
+// markers, so when the message contains a literal *, ~, ` or || outside code,
+// or a backtick inside a code block, it is converted to plain text instead
+// and Styled reports false.
+func Styled(markdown string) (string, bool) {
+	var literal bool
+	text := convert(markdown, signalStyles, &literal)
+	if literal {
+		return Plain(markdown), false
+	}
+	return text, true
+}
+
+// Plain strips Markdown markup and returns readable plain text, for sends
+// where styled text is disabled.
+func Plain(markdown string) string {
+	var literal bool
+	return convert(markdown, plainStyles, &literal)
+}
+
+// convert renders markdown with st, setting *literal when it passes through a
+// character styled text would read as markup
+func convert(markdown string, st styles, literal *bool) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inCode := false
+	fence := ""
+	for _, line := range lines {
+		if m := fenceRe.FindStringSubmatch(line); m != nil && (!inCode || m[1] == fence) {
+			inCode = !inCode
+			fence = m[1]
+			continue
+		}
+		if inCode {
+			if strings.TrimSpace(line) == "" {
+				out = append(out, "")
+			} else {
+				// A backtick would end the monospace span early.
+				if strings.Contains(line, "`") {
+					*literal = true
+				}
+				out = append(out, st.code(line))
+			}
+			continue
+		}
+		out = append(out, convertLine(line, st, literal))
+	}
+	return strings.TrimRight(strings.Join(out, "\n"), "\n")
+}
+
+// convertLine converts block-level markup of a single line outside code blocks
+func convertLine(line string, st styles, literal *bool) string {
+	if hruleRe.MatchString(line) {
+		return "──────────"
+	}
+	if m := headingRe.FindStringSubmatch(line); m != nil {
+		return st.bold(inline(m[1], st, literal))
+	}
+	if m := bulletRe.FindStringSubmatch(line); m != nil {
+		return m[1] + "• " + inline(m[2], st, literal)
+	}
+	if m := orderedRe.FindStringSubmatch(line); m != nil {
+		return m[1] + m[2] + ". " + inline(m[3], st, literal)
+	}
+	if m := blockquote.FindStringSubmatch(line); m != nil {
+		return "▎" + inline(m[1], st, literal)
+	}
+	return inline(line, st, literal)
+}
+
+// inline converts emphasis, strikethrough and code spans within a line
+func inline(s string, st styles, literal *bool) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~#[]()>-+.!|", s[i+1]) >= 0:
+			if isMarker(s[i+1]) {
+				*literal = true
+			}
+			out.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				out.WriteString(st.code(s[i+1 : i+1+end]))
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], "**") || strings.HasPrefix(s[i:], "__"):
+			if inner, n, ok := delimited(s, i, s[i:i+2]); ok {
+				out.WriteString(st.bold(inline(inner, st, literal)))
+				i += n
+				continue
+			}
+		case strings.HasPrefix(s[i:], "~~"):
+			if inner, n, ok := delimited(s, i, "~~"); ok {
+				out.WriteString(st.strike(inline(inner, st, literal)))
+				i += n
+				continue
+			}
+		case c == '*' || c == '_':
+			if inner, n, ok := delimited(s, i, string(c)); ok {
+				out.WriteString(st.italic(inline(inner, st, literal)))
+				i += n
+				continue
+			}
+		}
+		// A single | is not markup; only a pair opens a spoiler.
+		if isMarker(c) && (c != '|' || i+1 < len(s) && s[i+1] == '|') {
+			*literal = true
+		}
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// isMarker reports whether c starts a style in signal-cli's styled text:
+// *italic*, **bold**, ~strike~, `mono` or ||spoiler||
+func isMarker(c byte) bool {
+	return strings.IndexByte("*~`|", c) >= 0
+}
+
+// delimited finds the span opened by delim at s[start] and returns its inner
+// text and total length. Spans must not begin or end with whitespace, and
+// underscore spans must sit on word boundaries so snake_case survives.
+func delimited(s string, start int, delim string) (string, int, bool) {
+	open := start + len(delim)
+	if open >= len(s) || isSpace(s[open]) {
+		return "", 0, false
+	}
+	if delim[0] == '_' && start > 0 && isWord(s[start-1]) {
+		return "", 0, false
+	}
+	for j := open + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || isSpace(s[j-1]) {
+			continue
+		}
+		end := j + len(delim)
+		// A single delimiter must not be half of a double one.
+		if len(delim) == 1 && (s[j-1] == delim[0] || end < len(s) && s[end] == delim[0]) {
+			continue
+		}
+		if delim[0] == '_' && end < len(s) && isWord(s[end]) {
+			continue
+		}
+		return s[open:j], end - start, true
+	}
+	return "", 0, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,51 @@
+package format
+
+import "testing"
+
+func TestStyled(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		styled bool
+	}{
+		{"bold", "this is **bold**", "this is **bold**", true},
+		{"underscore bold", "this is __bold__", "this is **bold**", true},
+		{"italic", "an *italic* word", "an *italic* word", true},
+		{"underscore italic", "an _italic_ word", "an *italic* word", true},
+		{"snake case", "call my_func_name now", "call my_func_name now", true},
+		{"strikethrough", "~~gone~~", "~gone~", true},
+		{"inline code", "run `go test` **now**", "run `go test` **now**", true},
+		{"code span keeps markup", "`a*b*c`", "`a*b*c`", true},
+		{"nested", "*a **b** c*", "*a **b** c*", true},
+		{"heading", "## Summary", "**Summary**", true},
+		{"bullets", "- one\n* two\n+ three", "• one\n• two\n• three", true},
+		{"ordered", "1) first\n2. second", "1. first\n2. second", true},
+		{"code block", "```go\nx := 1\n\ny := 2\n```", "`x := 1`\n\n`y := 2`", true},
+		{"table pipes", "a | b", "a | b", true},
+		// Literal markers cannot be escaped in styled text, so these fall
+		// back to plain text rather than gain a style.
+		{"lone asterisk", "2 * 3 = 6", "2 * 3 = 6", false},
+		{"escaped", `\*not italic\*`, "*not italic*", false},
+		{"escaped with styles", `**bold** and \*stars\*`, "bold and *stars*", false},
+		{"literal tilde", "about ~5 minutes", "about ~5 minutes", false},
+		{"literal spoiler", "a || b", "a || b", false},
+		{"backtick in code block", "```sh\necho `date`\n```", "echo `date`", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, styled := Styled(tt.in)
+			if got != tt.want || styled != tt.styled {
+				t.Errorf("Styled(%q) = %q, %v, want %q, %v", tt.in, got, styled, tt.want, tt.styled)
+			}
+		})
+	}
+}
+
+func TestPlain(t *testing.T) {
+	in := "# Title\n\nSome **bold**, *italic* and `code`.\n\n- item\n\n```\nblock\n```"
+	want := "Title\n\nSome bold, italic and code.\n\n• item\n\nblock"
+	if got := Plain(in); got != want {
+		t.Errorf("Plain() = %q, want %q", got, want)
+	}
+}
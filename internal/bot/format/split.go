@@ -0,0 +1,133 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// spanMarkers are the markers of signal-cli's styled text, longest first
+var spanMarkers = []string{"**", "||", "*", "~", "`"}
+
+// maxClosers is the most bytes needed to close every span that can be open
+// at once: each marker at most once
+const maxClosers = 2 + 2 + 1 + 1 + 1
+
+// Split breaks text into parts of at most limit bytes. It cuts at paragraph
+// breaks where possible, then at line breaks (which keeps rendered code block
+// lines whole), then at spaces, and only cuts inside a word as a last resort.
+// Text that already fits is returned as a single part.
+//
+// When styled is set, text is signal-cli styled text. Parts are then cut
+// outside style spans where possible; otherwise the spans open at the cut are
+// closed at the end of the part and reopened at the start of the next.
+func Split(text string, limit int, styled bool) []string {
+	text = strings.TrimSpace(text)
+	if limit <= 0 || len(text) <= limit {
+		return []string{text}
+	}
+
+	// Too small to spare room for closing spans; cut as plain text.
+	if limit <= 2*maxClosers {
+		styled = false
+	}
+
+	var parts []string
+	for len(text) > limit {
+		cut := cutPoint(text, limit, styled)
+		var open []string
+		if styled {
+			open = openSpans(text[:cut])
+		}
+		if part := strings.TrimRight(text[:cut], " \t\n"); part != "" {
+			parts = append(parts, part+closers(open))
+		}
+		text = strings.Join(open, "") + strings.TrimLeft(text[cut:], " \t\n")
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// cutPoint returns the byte offset at which to end the next part of text.
+// For styled text a break outside any span is preferred; cuts inside a span
+// leave room to close it.
+func cutPoint(text string, limit int, styled bool) int {
+	// Ignore breaks in the first quarter so parts do not end up tiny.
+	min := limit / 4
+	if styled {
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			window := text[:limit]
+			for i := strings.LastIndex(window, sep); i > min; i = strings.LastIndex(window, sep) {
+				if len(openSpans(text[:i])) == 0 {
+					return i
+				}
+				window = window[:i]
+			}
+		}
+		limit -= maxClosers
+		min = limit / 4
+	}
+
+	window := text[:limit]
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(window, sep); i > min {
+			return i
+		}
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		// A single rune wider than limit; emit it whole rather than loop.
+		_, size := utf8.DecodeRuneInString(text)
+		return size
+	}
+	return cut
+}
+
+// openSpans returns the markers of the style spans still open at the end of
+// styled text, outermost first. Nothing inside a monospace span is markup.
+func openSpans(text string) []string {
+	var open []string
+	for i := 0; i < len(text); {
+		inCode := len(open) > 0 && open[len(open)-1] == "`"
+		matched := ""
+		for _, m := range spanMarkers {
+			if strings.HasPrefix(text[i:], m) && (!inCode || m == "`") {
+				matched = m
+				break
+			}
+		}
+		if matched == "" {
+			i++
+			continue
+		}
+		if j := indexOf(open, matched); j >= 0 {
+			open = append(open[:j], open[j+1:]...)
+		} else {
+			open = append(open, matched)
+		}
+		i += len(matched)
+	}
+	return open
+}
+
+// closers returns the markers closing open, innermost first
+func closers(open []string) string {
+	var sb strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString(open[i])
+	}
+	return sb.String()
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
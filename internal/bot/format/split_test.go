@@ -0,0 +1,94 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit_Fits(t *testing.T) {
+	parts := Split("short answer", 100, false)
+	if len(parts) != 1 || parts[0] != "short answer" {
+		t.Errorf("unexpected parts %q", parts)
+	}
+}
+
+func TestSplit_Paragraphs(t *testing.T) {
+	text := strings.Repeat("a", 40) + "\n\n" + strings.Repeat("b", 40) + "\n\n" + strings.Repeat("c", 40)
+	parts := Split(text, 90, false)
+
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d: %q", len(parts), parts)
+	}
+	if parts[0] != strings.Repeat("a", 40)+"\n\n"+strings.Repeat("b", 40) {
+		t.Errorf("unexpected first part %q", parts[0])
+	}
+	if parts[1] != strings.Repeat("c", 40) {
+		t.Errorf("unexpected second part %q", parts[1])
+	}
+}
+
+func TestSplit_Lines(t *testing.T) {
+	text := "`line one`\n`line two`\n`line three`"
+	for _, p := range Split(text, 24, true) {
+		if !strings.HasPrefix(p, "`") || !strings.HasSuffix(p, "`") {
+			t.Errorf("part %q cuts through a code line", p)
+		}
+	}
+}
+
+func TestSplit_LongWord(t *testing.T) {
+	text := strings.Repeat("é", 30)
+	parts := Split(text, 7, false)
+
+	if strings.Join(parts, "") != text {
+		t.Errorf("parts do not reassemble: %q", parts)
+	}
+	for _, p := range parts {
+		if len(p) > 7 {
+			t.Errorf("part %q exceeds limit", p)
+		}
+		if !strings.HasPrefix(p, "é") {
+			t.Errorf("part %q was cut inside a rune", p)
+		}
+	}
+}
+
+func TestSplit_AvoidsStyledSpans(t *testing.T) {
+	text := "intro words here **bold text that runs on** tail"
+	parts := Split(text, 30, true)
+
+	for _, p := range parts {
+		if len(openSpans(p)) != 0 {
+			t.Errorf("part %q has an unbalanced span", p)
+		}
+	}
+	if parts[0] != "intro words here" {
+		t.Errorf("expected the cut before the bold span, got %q", parts)
+	}
+}
+
+func TestSplit_ClosesAndReopensSpans(t *testing.T) {
+	text := "**" + strings.Repeat("bold ", 20) + "**"
+	parts := Split(text, 40, true)
+
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %q", parts)
+	}
+	for _, p := range parts {
+		if len(p) > 40 {
+			t.Errorf("part %q exceeds limit", p)
+		}
+		if !strings.HasPrefix(p, "**") || !strings.HasSuffix(p, "**") || len(openSpans(p)) != 0 {
+			t.Errorf("part %q does not carry the bold span", p)
+		}
+	}
+}
+
+func TestSplit_PlainIgnoresMarkers(t *testing.T) {
+	text := "a * b " + strings.Repeat("c ", 20)
+	for _, p := range Split(text, 16, false) {
+		if strings.Contains(p, "**") {
+			t.Errorf("plain part %q gained markers", p)
+		}
+	}
+}
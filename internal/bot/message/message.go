@@ -11,28 +11,62 @@ type Message struct {
 	SourceNumber string
 	SourceUUID   string
 	GroupID      string
+	Timestamp    int64
+	Edited       bool
+	Sync         bool   // sent from a device linked to the bot's own account
+	Destination  string // recipient of a 1:1 sync message
 	RawText      string
 	CleanText    string
 	Mentions     []signal.Mention
 	BotMentioned bool
 	Quote        *signal.Quote
-	EventHash    string
-	RawEvent     *signal.Envelope
+	Reaction     *signal.Reaction
+	Attachments  []signal.Attachment
+	// ExpiresInSeconds is the chat's disappearing-message timer; zero when off
+	ExpiresInSeconds int64
+	// ViewOnce means Attachments are view-once media
+	ViewOnce  bool
+	Sticker   *signal.Sticker
+	EventHash string
+	RawEvent  *signal.Envelope
 }
 
-// SimpleExtract extracts message information from a signal envelope
+// SimpleExtract extracts message information from a signal envelope. Edits are
+// extracted like new messages but keep the timestamp of the message they
+// replace, so replies and reactions refer to the original. Messages sent from
+// the bot account's linked devices are extracted from their sync message.
 func SimpleExtract(envelope *signal.Envelope, botNumber, botUUID string) Message {
 	var m Message
 
 	m.SourceNumber = envelope.SourceNumber
 	m.SourceUUID = envelope.SourceUUID
-	if envelope.DataMessage != nil {
-		dm := envelope.DataMessage
+	m.Timestamp = envelope.Timestamp
+	dm := envelope.DataMessage
+	if dm == nil && envelope.EditMessage != nil {
+		dm = envelope.EditMessage.DataMessage
+		m.Edited = true
+		m.Timestamp = envelope.EditMessage.TargetSentTimestamp
+	}
+	if dm == nil && envelope.SyncMessage != nil && envelope.SyncMessage.SentMessage != nil {
+		sent := envelope.SyncMessage.SentMessage
+		dm = &sent.DataMessage
+		m.Sync = true
+		m.Destination = firstNonEmpty(sent.DestinationNumber, sent.Destination, sent.DestinationUUID)
+		if sent.Timestamp != 0 {
+			m.Timestamp = sent.Timestamp
+		}
+	}
+	if dm != nil {
 		m.RawText = dm.Message
 		m.CleanText = strings.TrimSpace(dm.Message)
 		if dm.GroupInfo != nil {
 			m.GroupID = dm.GroupInfo.GroupID
 		}
+		m.Reaction = dm.Reaction
+		m.Attachments = dm.Attachments
+		m.ExpiresInSeconds = dm.ExpiresInSeconds
+		m.ViewOnce = dm.ViewOnce && len(dm.Attachments) > 0
+		m.Sticker = dm.Sticker
 		if len(dm.Mentions) > 0 {
 			m.Mentions = dm.Mentions
 			m.CleanText = RemoveMentionsFromText(m.RawText, m.Mentions)
@@ -53,7 +87,9 @@ func SimpleExtract(envelope *signal.Envelope, botNumber, botUUID string) Message
 				m.CleanText = strings.TrimSpace(m.CleanText)
 			}
 		}
-		if dm.Quote != nil && dm.Quote.Text != "" {
+		// Quotes of attachments without a caption have no text but still
+		// identify the message replied to.
+		if dm.Quote != nil {
 			q := &signal.Quote{
 				ID:     dm.Quote.ID,
 				Author: dm.Quote.Author,
@@ -65,9 +101,24 @@ func SimpleExtract(envelope *signal.Envelope, botNumber, botUUID string) Message
 			m.Quote = q
 		}
 	}
+	// Everything written to Note to Self from a linked device is for the bot.
+	if m.Sync && m.GroupID == "" && m.Destination != "" {
+		if NormalizePhone(m.Destination) == NormalizePhone(botNumber) || (botUUID != "" && m.Destination == botUUID) {
+			m.BotMentioned = true
+		}
+	}
 	return m
 }
 
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // RemoveMentionsFromText removes mentions from the message text
 func RemoveMentionsFromText(s string, mentions []signal.Mention) string {
 	if s == "" || len(mentions) == 0 {
@@ -114,6 +165,9 @@ func TargetLabel(m Message) string {
 	if m.GroupID != "" {
 		return "group " + m.GroupID
 	}
+	if m.Sync && m.Destination != "" {
+		return "linked device to " + m.Destination
+	}
 	if m.SourceNumber != "" {
 		return "user " + m.SourceNumber
 	}
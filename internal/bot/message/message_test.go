@@ -1,9 +1,26 @@
 package message
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
 )
 
+// envelopeFrom decodes a raw REST API event into a signal envelope
+func envelopeFrom(t *testing.T, event map[string]interface{}) *signal.Envelope {
+	t.Helper()
+	b, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	var w signal.EnvelopeWrapper
+	if err := json.Unmarshal(b, &w); err != nil {
+		t.Fatalf("unmarshal event: %v", err)
+	}
+	return &w.Envelope
+}
+
 func TestSimpleExtract_WithQuote(t *testing.T) {
 	botNumber := "+1234567890"
 	botUUID := "test-bot-uuid"
@@ -31,7 +48,7 @@ func TestSimpleExtract_WithQuote(t *testing.T) {
 		},
 	}
 
-	msg := SimpleExtract(event, botNumber, botUUID)
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, botUUID)
 
 	if msg.Quote == nil {
 		t.Fatal("Expected Quote to be extracted, but got nil")
@@ -75,7 +92,7 @@ func TestSimpleExtract_WithoutQuote(t *testing.T) {
 		},
 	}
 
-	msg := SimpleExtract(event, botNumber, botUUID)
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, botUUID)
 
 	if msg.Quote != nil {
 		t.Errorf("Expected Quote to be nil, got %+v", msg.Quote)
@@ -112,7 +129,7 @@ func TestSimpleExtract_QuoteWithAuthorUuid(t *testing.T) {
 		},
 	}
 
-	msg := SimpleExtract(event, botNumber, botUUID)
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, botUUID)
 
 	if msg.Quote == nil {
 		t.Fatal("Expected Quote to be extracted, but got nil")
@@ -127,7 +144,8 @@ func TestSimpleExtract_QuoteWithEmptyText(t *testing.T) {
 	botNumber := "+1234567890"
 	botUUID := "test-bot-uuid"
 
-	// Simulate a Signal event with a quote but empty text (should not set Quote)
+	// Simulate a reply to a caption-less attachment: the quote has no text but
+	// still identifies the message replied to
 	event := map[string]interface{}{
 		"envelope": map[string]interface{}{
 			"sourceNumber": "+9876543210",
@@ -149,9 +167,234 @@ func TestSimpleExtract_QuoteWithEmptyText(t *testing.T) {
 		},
 	}
 
-	msg := SimpleExtract(event, botNumber, botUUID)
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, botUUID)
 
-	if msg.Quote != nil {
-		t.Errorf("Expected Quote to be nil for empty text, got %+v", msg.Quote)
+	if msg.Quote == nil || msg.Quote.ID != 12345 || msg.Quote.Author != "+1111111111" || msg.Quote.Text != "" {
+		t.Errorf("Expected Quote with ID and author but no text, got %+v", msg.Quote)
+	}
+}
+
+func TestSimpleExtract_Reaction(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": "+9876543210",
+			"dataMessage": map[string]interface{}{
+				"reaction": map[string]interface{}{
+					"emoji":               "👍",
+					"targetAuthorNumber":  botNumber,
+					"targetSentTimestamp": float64(12345),
+					"isRemove":            false,
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if msg.Reaction == nil {
+		t.Fatal("Expected Reaction to be extracted, but got nil")
+	}
+
+	if msg.Reaction.Emoji != "👍" || msg.Reaction.TargetSentTimestamp != 12345 {
+		t.Errorf("Unexpected reaction: %+v", msg.Reaction)
+	}
+
+	if msg.BotMentioned {
+		t.Error("Expected BotMentioned to be false for a reaction")
+	}
+}
+
+func TestSimpleExtract_Attachments(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": "+9876543210",
+			"dataMessage": map[string]interface{}{
+				"message": "@bot what is this?",
+				"attachments": []interface{}{
+					map[string]interface{}{
+						"id":          "abc123.jpg",
+						"contentType": "image/jpeg",
+						"filename":    "photo.jpg",
+						"size":        float64(2048),
+						"width":       float64(640),
+						"height":      float64(480),
+					},
+				},
+				"mentions": []interface{}{
+					map[string]interface{}{
+						"start":  float64(0),
+						"length": float64(4),
+						"number": botNumber,
+					},
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(msg.Attachments))
+	}
+
+	a := msg.Attachments[0]
+	if a.ID != "abc123.jpg" || a.ContentType != "image/jpeg" || a.Filename != "photo.jpg" {
+		t.Errorf("Unexpected attachment: %+v", a)
+	}
+
+	if a.Size != 2048 || a.Width != 640 || a.Height != 480 {
+		t.Errorf("Unexpected attachment dimensions: %+v", a)
+	}
+}
+
+func TestSimpleExtract_EditMessage(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": "+9876543210",
+			"timestamp":    float64(2000),
+			"editMessage": map[string]interface{}{
+				"targetSentTimestamp": float64(1000),
+				"dataMessage": map[string]interface{}{
+					"message": "@bot what is 2+3?",
+					"mentions": []interface{}{
+						map[string]interface{}{
+							"start":  float64(0),
+							"length": float64(4),
+							"number": botNumber,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if !msg.Edited {
+		t.Error("Expected Edited to be true")
+	}
+
+	if msg.Timestamp != 1000 {
+		t.Errorf("Expected Timestamp to be the original 1000, got %d", msg.Timestamp)
+	}
+
+	if !msg.BotMentioned || msg.CleanText != "what is 2+3?" {
+		t.Errorf("Unexpected extraction: mentioned=%v text=%q", msg.BotMentioned, msg.CleanText)
+	}
+}
+
+func TestSimpleExtract_SyncNoteToSelf(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": botNumber,
+			"timestamp":    float64(3000),
+			"syncMessage": map[string]interface{}{
+				"sentMessage": map[string]interface{}{
+					"destinationNumber": botNumber,
+					"timestamp":         float64(3000),
+					"message":           "/help",
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if !msg.Sync {
+		t.Error("Expected Sync to be true")
+	}
+
+	if msg.Destination != botNumber || msg.Timestamp != 3000 {
+		t.Errorf("Unexpected destination %q or timestamp %d", msg.Destination, msg.Timestamp)
+	}
+
+	if !msg.BotMentioned || msg.CleanText != "/help" {
+		t.Errorf("Unexpected extraction: mentioned=%v text=%q", msg.BotMentioned, msg.CleanText)
+	}
+}
+
+func TestSimpleExtract_SyncToOtherUser(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": botNumber,
+			"syncMessage": map[string]interface{}{
+				"sentMessage": map[string]interface{}{
+					"destinationNumber": "+9876543210",
+					"message":           "see you later",
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if !msg.Sync || msg.Destination != "+9876543210" {
+		t.Errorf("Unexpected sync extraction: %+v", msg)
+	}
+
+	if msg.BotMentioned {
+		t.Error("Expected BotMentioned to be false for a message to another user")
+	}
+}
+
+func TestSimpleExtract_ExpirationTimer(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": "+9876543210",
+			"timestamp":    float64(1000),
+			"dataMessage": map[string]interface{}{
+				"message":          "@bot secret question",
+				"expiresInSeconds": float64(3600),
+				"mentions": []interface{}{
+					map[string]interface{}{
+						"start":  float64(0),
+						"length": float64(4),
+						"number": botNumber,
+					},
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if msg.ExpiresInSeconds != 3600 {
+		t.Errorf("Expected ExpiresInSeconds 3600, got %d", msg.ExpiresInSeconds)
+	}
+}
+
+func TestSimpleExtract_Sticker(t *testing.T) {
+	botNumber := "+1234567890"
+
+	event := map[string]interface{}{
+		"envelope": map[string]interface{}{
+			"sourceNumber": "+9876543210",
+			"timestamp":    float64(1000),
+			"dataMessage": map[string]interface{}{
+				"sticker": map[string]interface{}{
+					"packId":    "8f1b2c",
+					"stickerId": float64(4),
+					"emoji":     "😂",
+				},
+			},
+		},
+	}
+
+	msg := SimpleExtract(envelopeFrom(t, event), botNumber, "")
+
+	if msg.Sticker == nil || msg.Sticker.PackID != "8f1b2c" || msg.Sticker.StickerID != 4 || msg.Sticker.Emoji != "😂" {
+		t.Errorf("Unexpected sticker %+v", msg.Sticker)
 	}
 }
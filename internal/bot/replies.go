@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedReplies bounds how many sent messages are remembered
+const maxTrackedReplies = 1000
+
+// replyKey identifies a request by its author and original sent timestamp
+type replyKey struct {
+	Author    string
+	Timestamp int64
+}
+
+// replyLog remembers which request each bot message answered, so that edits
+// to the request can revise the reply and only the asker can delete it.
+// Replies in chats with disappearing messages are forgotten when they expire.
+type replyLog struct {
+	mu      sync.Mutex
+	replies map[replyKey]int64  // request -> first reply timestamp
+	askers  map[int64]replyKey  // reply timestamp -> request
+	expires map[int64]time.Time // reply timestamp -> when it disappears
+	order   []int64
+}
+
+func newReplyLog() *replyLog {
+	return &replyLog{
+		replies: make(map[replyKey]int64),
+		askers:  make(map[int64]replyKey),
+		expires: make(map[int64]time.Time),
+	}
+}
+
+// Record stores that the message sent at replyTimestamp answered key,
+// evicting the oldest entry when full. A ttl above zero forgets the entry
+// once the reply has disappeared from the chat.
+func (r *replyLog) Record(key replyKey, replyTimestamp int64, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked()
+	if ttl > 0 {
+		r.expires[replyTimestamp] = time.Now().Add(ttl)
+	}
+	if _, ok := r.askers[replyTimestamp]; !ok {
+		r.order = append(r.order, replyTimestamp)
+		if len(r.order) > maxTrackedReplies {
+			oldest := r.order[0]
+			r.order = r.order[1:]
+			if k, ok := r.askers[oldest]; ok && r.replies[k] == oldest {
+				delete(r.replies, k)
+			}
+			delete(r.askers, oldest)
+			delete(r.expires, oldest)
+		}
+	}
+	if _, ok := r.replies[key]; !ok {
+		r.replies[key] = replyTimestamp
+	}
+	r.askers[replyTimestamp] = key
+}
+
+// Lookup returns the timestamp of the first reply sent for key, if known
+func (r *replyLog) Lookup(key replyKey) (int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked()
+	ts, ok := r.replies[key]
+	return ts, ok
+}
+
+// Asker returns the author of the request answered by the message sent at replyTimestamp
+func (r *replyLog) Asker(replyTimestamp int64) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked()
+	key, ok := r.askers[replyTimestamp]
+	return key.Author, ok
+}
+
+// expireLocked forgets replies whose disappearing-message timer has run out
+func (r *replyLog) expireLocked() {
+	now := time.Now()
+	for ts, at := range r.expires {
+		if now.Before(at) {
+			continue
+		}
+		if k, ok := r.askers[ts]; ok && r.replies[k] == ts {
+			delete(r.replies, k)
+		}
+		delete(r.askers, ts)
+		delete(r.expires, ts)
+	}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	supervisorMinBackoff = 1 * time.Second
+	supervisorMaxBackoff = 1 * time.Minute
+)
+
+// Supervisor runs one receive loop per account. A loop that stops or panics
+// before shutdown is restarted with exponential backoff, so one failing
+// account does not take the others down.
+type Supervisor struct {
+	Bots []*Bot
+}
+
+// NewSupervisor creates a supervisor for bots, which normally share the LLM
+// client and deduper but each have their own Signal account
+func NewSupervisor(bots ...*Bot) *Supervisor {
+	return &Supervisor{Bots: bots}
+}
+
+// Run starts every bot and blocks until ctx is cancelled and all loops have stopped
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range s.Bots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.supervise(ctx, b)
+		}()
+	}
+	wg.Wait()
+}
+
+// supervise keeps b's receive loop running until ctx is cancelled
+func (s *Supervisor) supervise(ctx context.Context, b *Bot) {
+	backoff := supervisorMinBackoff
+	for {
+		log.Printf("Starting receive loop for %s", b.label())
+		started := time.Now()
+		runRecovered(ctx, b)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > supervisorMaxBackoff {
+			backoff = supervisorMinBackoff
+		}
+		log.Printf("Receive loop for %s stopped, restarting in %s", b.label(), backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > supervisorMaxBackoff {
+			backoff = supervisorMaxBackoff
+		}
+	}
+}
+
+// runRecovered runs b.Start, turning a panic into a log line
+func runRecovered(ctx context.Context, b *Bot) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Receive loop for %s panicked: %v", b.label(), r)
+		}
+	}()
+	b.Start(ctx)
+}
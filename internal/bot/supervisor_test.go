@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal/signaltest"
+	"github.com/afeedhshaji/signal-llm-bot/pkg/deduper"
+)
+
+func TestSupervisor_RunsEveryAccount(t *testing.T) {
+	dedup := deduper.New(time.Minute)
+	t.Cleanup(dedup.Stop)
+
+	numbers := []string{testBotNumber, "+1555000111"}
+	var bots []*Bot
+	var transports []*signaltest.Transport
+	for _, number := range numbers {
+		transport := signaltest.New()
+		b := NewBot(transport, &stubLLM{answer: "hi from " + number}, time.Second, dedup, number)
+		b.ReceiveMode = ReceiveModeWebSocket
+		bots = append(bots, b)
+		transports = append(transports, transport)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewSupervisor(bots...).Run(ctx)
+		close(done)
+	}()
+
+	// The same message reaches both accounts; the shared deduper must not
+	// drop the second copy.
+	for i, transport := range transports {
+		transport.Inject(signal.Envelope{SourceNumber: testUser, Timestamp: 100, DataMessage: &signal.DataMessage{
+			Message:  "@bot hello",
+			Mentions: []signal.Mention{{Start: 0, Length: 4, Number: numbers[i]}},
+		}})
+	}
+	for i, transport := range transports {
+		deadline := time.After(2 * time.Second)
+		for len(transport.Sent()) == 0 {
+			select {
+			case <-deadline:
+				t.Fatalf("timed out waiting for reply from %s", numbers[i])
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if got := transport.Sent()[0].Message.Text; got != "hi from "+numbers[i] {
+			t.Errorf("account %s replied %q", numbers[i], got)
+		}
+	}
+	cancel()
+	<-done
+}
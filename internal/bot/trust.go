@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/afeedhshaji/signal-llm-bot/internal/bot/message"
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
+)
+
+// Trust policies for contacts whose safety number changed. signal-cli trusts
+// the first key it sees for a contact; the policy decides about later ones.
+const (
+	// TrustOnFirstUse leaves every changed key to an admin's /trust, like
+	// signal-cli's own on-first-use trust mode
+	TrustOnFirstUse = "tofu"
+	// TrustUnverified trusts a changed key automatically unless the contact's
+	// key was verified, by an admin's "/trust <number> <safety number>" or on
+	// a linked device before the change
+	TrustUnverified = "unverified"
+	// TrustAlways trusts every changed key automatically
+	TrustAlways = "always"
+)
+
+// resolveUntrusted handles a send that failed because a contact's safety
+// number changed, and reports whether the new key is now trusted so the send
+// can be retried. Keys the policy does not accept are left to the admins.
+func (b *Bot) resolveUntrusted(ctx context.Context, msg message.Message, untrusted *signal.UntrustedIdentityError) bool {
+	number := untrusted.Number
+	if number == "" && msg.GroupID == "" {
+		number = authorOf(msg)
+	}
+	if number == "" {
+		log.Printf("Untrusted identity in %s, but the contact is unknown: %v", message.TargetLabel(msg), untrusted)
+		return false
+	}
+
+	if b.autoTrust(ctx, number) {
+		if err := b.SignalClient.TrustIdentity(ctx, number, ""); err != nil {
+			log.Printf("Error trusting new identity of %s: %v", number, err)
+		} else {
+			log.Printf("Trusted new identity of %s (policy %s)", number, b.TrustPolicy)
+			delete(b.pendingTrust, number)
+			return true
+		}
+	}
+	b.requestTrust(ctx, number)
+	return false
+}
+
+// autoTrust reports whether the trust policy accepts a new key for number
+// without an admin
+func (b *Bot) autoTrust(ctx context.Context, number string) bool {
+	switch b.TrustPolicy {
+	case TrustAlways:
+		return true
+	case TrustUnverified:
+		// Once the key has changed signal-cli only lists the new, untrusted
+		// key, so whether the contact was verified comes from what was seen earlier.
+		b.loadVerified(ctx)
+		if b.verified[number] {
+			log.Printf("Identity of %s was verified before, leaving its new key to the admins", number)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// loadVerified remembers the contacts whose current key is verified, so a
+// later key change can be recognised as a change of a verified key
+func (b *Bot) loadVerified(ctx context.Context) {
+	identities, err := b.SignalClient.ListIdentities(ctx)
+	if err != nil {
+		log.Printf("Error listing identities: %v", err)
+		return
+	}
+	for _, id := range identities {
+		if id.Status != signal.TrustTrustedVerified {
+			continue
+		}
+		if id.Number != "" {
+			b.verified[id.Number] = true
+		}
+		if id.UUID != "" {
+			b.verified[id.UUID] = true
+		}
+	}
+}
+
+// requestTrust tells the admins, once per contact, that number's new safety
+// number needs their approval before the bot can message them again
+func (b *Bot) requestTrust(ctx context.Context, number string) {
+	if b.pendingTrust[number] {
+		return
+	}
+	b.pendingTrust[number] = true
+	log.Printf("Safety number of %s changed; waiting for an admin to trust it", number)
+	if len(b.Admins) == 0 {
+		log.Printf("No admins configured to approve %s; trust it with /trust from a linked device", number)
+		return
+	}
+	notice := fmt.Sprintf("⚠️ The safety number of %s changed, so I can't message them until it is trusted. Verify it with them, then send '@bot /trust %s'.", number, number)
+	for _, admin := range b.Admins {
+		if _, err := b.SignalClient.Send(ctx, admin, signal.OutgoingMessage{Text: notice}); err != nil {
+			log.Printf("Error notifying admin %s: %v", admin, err)
+		}
+	}
+}
+
+// handleTrustCommand lets admins trust a contact's new identity key with
+// "/trust <number>", or mark a specific key verified with
+// "/trust <number> <safety number>"
+func (b *Bot) handleTrustCommand(ctx context.Context, msg message.Message) {
+	if !b.isAdmin(msg) {
+		log.Printf("Refusing trust change requested by %s", authorOf(msg))
+		b.sendResponse(ctx, msg, "Only an admin can trust safety numbers.")
+		return
+	}
+
+	args := strings.TrimSpace(strings.TrimSpace(msg.CleanText)[len("/trust"):])
+	number, safetyNumber, _ := strings.Cut(args, " ")
+	if number == "" {
+		b.sendResponse(ctx, msg, "To trust a changed safety number, send '@bot /trust <number>' or '@bot /trust <number> <safety number>'.")
+		return
+	}
+	// Safety numbers are often copied in groups of five digits.
+	safetyNumber = strings.Join(strings.Fields(safetyNumber), "")
+
+	if err := b.SignalClient.TrustIdentity(ctx, number, safetyNumber); err != nil {
+		log.Printf("Error trusting identity of %s: %v", number, err)
+		b.react(ctx, msg, b.Reactions.Failed)
+		b.sendErrorResponse(ctx, msg)
+		return
+	}
+	delete(b.pendingTrust, number)
+	if safetyNumber != "" {
+		b.verified[number] = true
+	}
+	b.sendResponse(ctx, msg, fmt.Sprintf("Trusted the safety number of %s.", number))
+}
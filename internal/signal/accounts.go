@@ -0,0 +1,186 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ListAccounts returns the numbers of all accounts registered or linked with
+// the REST API, from /v1/accounts
+func (c *SignalClient) ListAccounts(ctx context.Context) ([]string, error) {
+	body, err := c.do(ctx, "GET", "/v1/accounts", nil, c.Timeouts.Control, retryIdempotent)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []string
+	if err := json.Unmarshal(body, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to decode accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// QRCodeLink starts linking the REST API as a new device named deviceName to
+// an existing Signal account and returns the provisioning QR code as a PNG.
+// The link completes in the background once the code is scanned from the
+// phone; poll ListAccounts to see it appear.
+func (c *SignalClient) QRCodeLink(ctx context.Context, deviceName string) ([]byte, error) {
+	fmt.Printf("[signal] Requesting device link QR code for %q\n", deviceName)
+	return c.do(ctx, "GET", "/v1/qrcodelink?device_name="+url.QueryEscape(deviceName), nil, c.Timeouts.Upload, retryIdempotent)
+}
+
+// ListContacts returns the account's contacts from /v1/contacts
+func (c *SignalClient) ListContacts(ctx context.Context) ([]Contact, error) {
+	body, err := c.do(ctx, "GET", "/v1/contacts/"+c.Number, nil, c.Timeouts.Control, retryIdempotent)
+	if err != nil {
+		return nil, err
+	}
+	var contacts []Contact
+	if err := json.Unmarshal(body, &contacts); err != nil {
+		return nil, fmt.Errorf("failed to decode contacts: %w", err)
+	}
+	return contacts, nil
+}
+
+// ListIdentities returns the identity keys known to the account from /v1/identities
+func (c *SignalClient) ListIdentities(ctx context.Context) ([]Identity, error) {
+	body, err := c.do(ctx, "GET", "/v1/identities/"+c.Number, nil, c.Timeouts.Control, retryIdempotent)
+	if err != nil {
+		return nil, err
+	}
+	var identities []Identity
+	if err := json.Unmarshal(body, &identities); err != nil {
+		return nil, fmt.Errorf("failed to decode identities: %w", err)
+	}
+	return identities, nil
+}
+
+// Identity trust levels reported in Identity.Status
+const (
+	TrustUntrusted         = "UNTRUSTED"
+	TrustTrustedUnverified = "TRUSTED_UNVERIFIED"
+	TrustTrustedVerified   = "TRUSTED_VERIFIED"
+)
+
+// TrustIdentity trusts the identity key of number via /v1/identities. With a
+// safetyNumber only the matching key is trusted and marked verified;
+// otherwise all known keys of number are trusted.
+func (c *SignalClient) TrustIdentity(ctx context.Context, number, safetyNumber string) error {
+	fmt.Printf("[signal] Trusting identity of %s (verified=%v)\n", number, safetyNumber != "")
+	payload := map[string]interface{}{}
+	if safetyNumber != "" {
+		payload["verified_safety_number"] = safetyNumber
+	} else {
+		payload["trust_all_known_keys"] = true
+	}
+	_, err := c.do(ctx, "PUT", "/v1/identities/"+c.Number+"/trust/"+url.PathEscape(number), payload, c.Timeouts.Control, retryIdempotent)
+	return err
+}
+
+// SelfUUID looks up the account's own ACI (UUID). Depending on the REST API
+// version it is reported by /v1/accounts, or the account lists itself among
+// its contacts or identities; each is tried in turn.
+func (c *SignalClient) SelfUUID(ctx context.Context) (string, error) {
+	var errs []error
+	uuid, err := c.accountUUID(ctx)
+	if uuid != "" {
+		return uuid, nil
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if contacts, err := c.ListContacts(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, ct := range contacts {
+			if ct.UUID != "" && sameNumber(ct.Number, c.Number) {
+				return ct.UUID, nil
+			}
+		}
+	}
+	if identities, err := c.ListIdentities(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, id := range identities {
+			if id.UUID != "" && sameNumber(id.Number, c.Number) {
+				return id.UUID, nil
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("uuid of %s not found: %w", c.Number, errors.Join(errs...))
+	}
+	return "", fmt.Errorf("uuid of %s not reported by accounts, contacts or identities", c.Number)
+}
+
+// accountUUID returns the UUID of this account from /v1/accounts, for REST
+// API versions that list accounts as objects rather than plain numbers
+func (c *SignalClient) accountUUID(ctx context.Context) (string, error) {
+	body, err := c.do(ctx, "GET", "/v1/accounts", nil, c.Timeouts.Control, retryIdempotent)
+	if err != nil {
+		return "", err
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return "", fmt.Errorf("failed to decode accounts: %w", err)
+	}
+	for _, raw := range entries {
+		var a struct {
+			Number string `json:"number"`
+			UUID   string `json:"uuid"`
+		}
+		if json.Unmarshal(raw, &a) == nil && a.UUID != "" && sameNumber(a.Number, c.Number) {
+			return a.UUID, nil
+		}
+	}
+	return "", nil
+}
+
+// sameNumber compares phone numbers ignoring formatting spaces
+func sameNumber(a, b string) bool {
+	return a != "" && strings.ReplaceAll(a, " ", "") == strings.ReplaceAll(b, " ", "")
+}
+
+// UpdateProfile sets the account's profile name, about text and avatar via
+// /v1/profiles. The REST API removes the avatar when none is sent, so with an
+// empty p.AvatarPath the last avatar set through this client is sent again.
+func (c *SignalClient) UpdateProfile(ctx context.Context, p Profile) error {
+	fmt.Printf("[signal] Updating profile of %s: name=%q about=%q avatar=%q\n", c.Number, p.Name, p.About, p.AvatarPath)
+	var avatar []byte
+	if p.AvatarPath != "" {
+		var err error
+		if avatar, err = os.ReadFile(p.AvatarPath); err != nil {
+			return fmt.Errorf("failed to read avatar: %w", err)
+		}
+	} else {
+		c.avatarMu.Lock()
+		avatar = c.avatar
+		c.avatarMu.Unlock()
+	}
+
+	payload := map[string]interface{}{
+		"name":  p.Name,
+		"about": p.About,
+	}
+	timeout := c.Timeouts.Control
+	if len(avatar) > 0 {
+		payload["base64_avatar"] = base64.StdEncoding.EncodeToString(avatar)
+		timeout = c.Timeouts.Upload
+	} else {
+		fmt.Printf("[signal] No avatar known for %s; the profile update removes the current one\n", c.Number)
+	}
+	if _, err := c.do(ctx, "PUT", "/v1/profiles/"+c.Number, payload, timeout, retryIdempotent); err != nil {
+		return err
+	}
+	if len(avatar) > 0 {
+		c.avatarMu.Lock()
+		c.avatar = avatar
+		c.avatarMu.Unlock()
+	}
+	return nil
+}
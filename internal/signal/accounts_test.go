@@ -0,0 +1,65 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfUUID_FallsBackToIdentities(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/accounts":
+			w.Write([]byte(`["+1234567890"]`))
+		case "/v1/contacts/+1234567890":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/v1/identities/+1234567890":
+			w.Write([]byte(`[{"number":"+1999","uuid":"other"},{"number":"+1234567890","uuid":"self-uuid"}]`))
+		}
+	})
+
+	uuid, err := c.SelfUUID(ctx)
+	if err != nil || uuid != "self-uuid" {
+		t.Fatalf("SelfUUID() = %q, %v", uuid, err)
+	}
+}
+
+func TestSelfUUID_NotReported(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	if uuid, err := c.SelfUUID(ctx); err == nil {
+		t.Fatalf("SelfUUID() = %q, expected an error", uuid)
+	}
+}
+
+func TestUpdateProfile_KeepsAvatar(t *testing.T) {
+	ctx := context.Background()
+	var avatars []interface{}
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		avatars = append(avatars, payload["base64_avatar"])
+	})
+	path := filepath.Join(t.TempDir(), "avatar.png")
+	if err := os.WriteFile(path, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpdateProfile(ctx, Profile{Name: "Bot", AvatarPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateProfile(ctx, Profile{Name: "Bot", About: "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(avatars) != 2 || avatars[0] != "cG5n" || avatars[1] != "cG5n" {
+		t.Fatalf("expected the avatar to be sent with both updates, got %v", avatars)
+	}
+}
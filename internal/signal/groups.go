@@ -0,0 +1,104 @@
+package signal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// groupRefreshCooldown limits how often misses of the same group ID may reload
+// the group list
+const groupRefreshCooldown = 10 * time.Second
+
+// groupLister is the part of SignalClient a GroupDirectory needs
+type groupLister interface {
+	ListGroups(ctx context.Context) ([]Group, error)
+}
+
+// GroupDirectory caches group metadata keyed by internal group ID. The list is
+// reloaded when it is older than the TTL or a lookup misses, so groups the bot
+// just joined are found at once; if a reload fails the previously cached
+// entries keep being served.
+type GroupDirectory struct {
+	lister groupLister
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	groups    map[string]Group
+	fetchedAt time.Time
+	missedAt  map[string]time.Time // last reload that did not find an ID
+}
+
+// NewGroupDirectory creates a directory backed by lister that refreshes every ttl
+func NewGroupDirectory(lister groupLister, ttl time.Duration) *GroupDirectory {
+	return &GroupDirectory{lister: lister, ttl: ttl, groups: make(map[string]Group), missedAt: make(map[string]time.Time)}
+}
+
+// Lookup returns the group with the given internal ID
+func (d *GroupDirectory) Lookup(ctx context.Context, internalID string) (Group, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	g, ok := d.groups[internalID]
+	stale := time.Since(d.fetchedAt) > d.ttl
+	// An unknown ID reloads the list right away; the cooldown only stops
+	// repeated misses of the same ID from reloading it on every message.
+	missRefresh := !ok && time.Since(d.missedAt[internalID]) > groupRefreshCooldown
+	if stale || missRefresh {
+		if err := d.refreshLocked(ctx); err != nil {
+			if ok {
+				log.Printf("[signal] Group refresh failed, using cached entry: %v", err)
+				return g, nil
+			}
+			return Group{}, err
+		}
+		g, ok = d.groups[internalID]
+	}
+	if !ok {
+		d.missedAt[internalID] = d.fetchedAt
+		return Group{}, fmt.Errorf("group not found for internal id: %s", internalID)
+	}
+	return g, nil
+}
+
+// PublicID returns the public ID used to send to the group with the given internal ID
+func (d *GroupDirectory) PublicID(ctx context.Context, internalID string) (string, error) {
+	g, err := d.Lookup(ctx, internalID)
+	if err != nil {
+		return "", err
+	}
+	if g.ID == "" {
+		return "", fmt.Errorf("public group id not found for internal id: %s", internalID)
+	}
+	return g.ID, nil
+}
+
+// Refresh reloads the group list immediately
+func (d *GroupDirectory) Refresh(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.refreshLocked(ctx)
+}
+
+func (d *GroupDirectory) refreshLocked(ctx context.Context) error {
+	groups, err := d.lister.ListGroups(ctx)
+	if err != nil {
+		return err
+	}
+	fresh := make(map[string]Group, len(groups))
+	for _, g := range groups {
+		if g.InternalID != "" {
+			fresh[g.InternalID] = g
+		}
+	}
+	d.groups = fresh
+	d.fetchedAt = time.Now()
+	for id, at := range d.missedAt {
+		if d.fetchedAt.Sub(at) > groupRefreshCooldown {
+			delete(d.missedAt, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubLister struct {
+	groups []Group
+	err    error
+	calls  int
+}
+
+func (s *stubLister) ListGroups(ctx context.Context) ([]Group, error) {
+	s.calls++
+	return s.groups, s.err
+}
+
+func TestGroupDirectory_CachesLookups(t *testing.T) {
+	ctx := context.Background()
+	lister := &stubLister{groups: []Group{{ID: "group.pub", InternalID: "int", Name: "Friends"}}}
+	dir := NewGroupDirectory(lister, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		id, err := dir.PublicID(ctx, "int")
+		if err != nil || id != "group.pub" {
+			t.Fatalf("PublicID() = %q, %v", id, err)
+		}
+	}
+	if lister.calls != 1 {
+		t.Errorf("expected 1 list call, got %d", lister.calls)
+	}
+}
+
+func TestGroupDirectory_ServesStaleOnError(t *testing.T) {
+	ctx := context.Background()
+	lister := &stubLister{groups: []Group{{ID: "group.pub", InternalID: "int"}}}
+	dir := NewGroupDirectory(lister, time.Hour)
+	if err := dir.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	dir.fetchedAt = time.Now().Add(-2 * time.Hour)
+	lister.err = errors.New("unreachable")
+
+	g, err := dir.Lookup(ctx, "int")
+	if err != nil || g.ID != "group.pub" {
+		t.Errorf("Lookup() = %+v, %v; want cached group", g, err)
+	}
+}
+
+func TestGroupDirectory_Miss(t *testing.T) {
+	ctx := context.Background()
+	lister := &stubLister{}
+	dir := NewGroupDirectory(lister, time.Hour)
+
+	if _, err := dir.Lookup(ctx, "unknown"); err == nil {
+		t.Error("expected an error for an unknown group")
+	}
+	// A second miss inside the cooldown must not reload the list.
+	dir.Lookup(ctx, "unknown")
+	if lister.calls != 1 {
+		t.Errorf("expected 1 list call, got %d", lister.calls)
+	}
+}
+
+func TestGroupDirectory_NewGroupRefreshesAtOnce(t *testing.T) {
+	ctx := context.Background()
+	lister := &stubLister{groups: []Group{{ID: "group.old", InternalID: "old"}}}
+	dir := NewGroupDirectory(lister, time.Hour)
+	if err := dir.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bot joins a group right after the list was loaded.
+	lister.groups = append(lister.groups, Group{ID: "group.new", InternalID: "new"})
+	id, err := dir.PublicID(ctx, "new")
+	if err != nil || id != "group.new" {
+		t.Fatalf("PublicID() = %q, %v; want the new group", id, err)
+	}
+	if lister.calls != 2 {
+		t.Errorf("expected 2 list calls, got %d", lister.calls)
+	}
+}
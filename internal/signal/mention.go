@@ -0,0 +1,30 @@
+package signal
+
+import "unicode/utf16"
+
+// MentionPlaceholder is the character Signal clients replace with the
+// mentioned contact's name
+const MentionPlaceholder = "\uFFFC"
+
+// MentionAt inserts a mention placeholder into text at byte offset pos and
+// returns the new text with a mention of author covering it. Signal measures
+// mention ranges in UTF-16 code units, so the start is converted accordingly.
+func MentionAt(text string, pos int, author string) (string, MentionRequest) {
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(text) {
+		pos = len(text)
+	}
+	mention := MentionRequest{
+		Author: author,
+		Start:  UTF16Len(text[:pos]),
+		Length: UTF16Len(MentionPlaceholder),
+	}
+	return text[:pos] + MentionPlaceholder + text[pos:], mention
+}
+
+// UTF16Len returns the length of s in UTF-16 code units
+func UTF16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
@@ -0,0 +1,24 @@
+package signal
+
+import "testing"
+
+func TestMentionAt(t *testing.T) {
+	text, m := MentionAt("hi 😀 there", len("hi 😀 "), "uuid-1")
+
+	if text != "hi 😀 "+MentionPlaceholder+"there" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	// "hi " is 3 code units and the emoji is a surrogate pair, plus the space.
+	if m.Start != 6 || m.Length != 1 || m.Author != "uuid-1" {
+		t.Errorf("unexpected mention %+v", m)
+	}
+}
+
+func TestMentionAt_Start(t *testing.T) {
+	text, m := MentionAt("hello", 0, "+123")
+
+	if text != MentionPlaceholder+"hello" || m.Start != 0 || m.Length != 1 {
+		t.Errorf("unexpected result %q %+v", text, m)
+	}
+}
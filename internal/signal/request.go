@@ -0,0 +1,304 @@
+package signal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Calls that are safe
+// to repeat are retried after network errors, timeouts and 5xx responses;
+// calls with side effects, such as sending a message, only when the
+// connection to the REST API could not be established. 4xx responses are
+// never retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by NewSignalClient
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// APIError is a non-2xx response from the REST API
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+// UnreachableError means the REST API could not be reached, even after retries
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string { return "signal api unreachable: " + e.Err.Error() }
+func (e *UnreachableError) Unwrap() error { return e.Err }
+
+// RateLimitError means Signal or the REST API is rate limiting the account.
+// RetryAfter is zero when the server did not say how long to wait.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Response   *APIError
+}
+
+func (e *RateLimitError) Error() string { return "signal rate limited: " + e.Response.Error() }
+func (e *RateLimitError) Unwrap() error { return e.Response }
+
+// UnregisteredRecipientError means a recipient is not registered with Signal
+type UnregisteredRecipientError struct {
+	Response *APIError
+}
+
+func (e *UnregisteredRecipientError) Error() string {
+	return "signal recipient not registered: " + e.Response.Error()
+}
+func (e *UnregisteredRecipientError) Unwrap() error { return e.Response }
+
+// ProofRequiredError means Signal wants a captcha challenge solved before the
+// account may send again. Token is the challenge token, when reported.
+type ProofRequiredError struct {
+	Token    string
+	Response *APIError
+}
+
+func (e *ProofRequiredError) Error() string { return "signal proof required: " + e.Response.Error() }
+func (e *ProofRequiredError) Unwrap() error { return e.Response }
+
+// Timeouts bounds each kind of REST API call per attempt. A zero timeout
+// leaves the call bounded only by its context.
+type Timeouts struct {
+	// Receive bounds polling /v1/receive
+	Receive time.Duration
+	// Send bounds text sends and edits
+	Send time.Duration
+	// Upload bounds sends with attachments and attachment downloads
+	Upload time.Duration
+	// Control bounds everything else: reactions, receipts, typing, groups
+	Control time.Duration
+}
+
+// DefaultTimeouts is used by NewSignalClient
+var DefaultTimeouts = Timeouts{Receive: 15 * time.Second, Send: 10 * time.Second, Upload: 60 * time.Second, Control: 10 * time.Second}
+
+// DefaultMaxConnsPerHost is the connection limit of NewHTTPClient(0)
+const DefaultMaxConnsPerHost = 16
+
+// NewHTTPClient returns a client that opens at most maxConnsPerHost
+// connections to the REST API at once and keeps them open for reuse. Calls
+// beyond the limit wait for a free connection. Per-call timeouts come from the
+// request context, so the client itself has none.
+func NewHTTPClient(maxConnsPerHost int) *http.Client {
+	if maxConnsPerHost <= 0 {
+		maxConnsPerHost = DefaultMaxConnsPerHost
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxConnsPerHost = maxConnsPerHost
+	t.MaxIdleConnsPerHost = maxConnsPerHost
+	if t.MaxIdleConns < maxConnsPerHost {
+		t.MaxIdleConns = maxConnsPerHost
+	}
+	return &http.Client{Transport: t}
+}
+
+// UntrustedIdentityError means a recipient's safety number changed and their
+// new identity key must be trusted before messages reach them again. Number
+// is the affected contact, when reported.
+type UntrustedIdentityError struct {
+	Number   string
+	Response *APIError
+}
+
+func (e *UntrustedIdentityError) Error() string {
+	return "signal identity not trusted: " + e.Response.Error()
+}
+func (e *UntrustedIdentityError) Unwrap() error { return e.Response }
+
+var untrustedNumberRe = regexp.MustCompile(`(?i)untrusted ?identity[^"+]*"?(\+?[0-9a-f][0-9a-f-]{5,})`)
+
+var proofTokenRe = regexp.MustCompile(`(?i)token[=:\s"]+([0-9a-z-]+)`)
+
+// retryMode says when a failed call may be sent again; each call opts in
+type retryMode int
+
+const (
+	// retryBeforeSend retries only failures to connect, when the REST API
+	// cannot have seen the request. Used for calls with side effects.
+	retryBeforeSend retryMode = iota
+	// retryIdempotent also retries timeouts, broken connections and 5xx
+	// responses, for calls that are safe to repeat
+	retryIdempotent
+)
+
+// do performs a JSON request against the REST API and returns the response
+// body of a 2xx response; failures are returned as the typed errors above
+func (c *SignalClient) do(ctx context.Context, method, path string, payload interface{}, timeout time.Duration, retry retryMode) ([]byte, error) {
+	resp, err := c.request(ctx, method, path, payload, timeout, retry)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &UnreachableError{Err: err}
+	}
+	return body, nil
+}
+
+// request performs a request, retrying as allowed by retry, and returns a
+// 2xx response whose body the caller must close. timeout bounds each attempt;
+// ctx bounds the whole call including the waits between attempts.
+func (c *SignalClient) request(ctx context.Context, method, path string, payload interface{}, timeout time.Duration, retry retryMode) (*http.Response, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+	}
+	url := strings.TrimRight(c.APIURL, "/") + path
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	policy := c.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	var lastErr error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := policy.backoff(attempt)
+			fmt.Printf("[signal] Retrying %s %s in %s (attempt %d/%d): %v\n", method, path, delay, attempt+1, policy.MaxAttempts, lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		req, err := http.NewRequestWithContext(attemptCtx, method, url, body)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if data != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			cancel()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = &UnreachableError{Err: err}
+			if retry == retryIdempotent || isDialError(err) {
+				continue
+			}
+			return nil, lastErr
+		}
+		if resp.StatusCode < 300 {
+			// The attempt deadline must outlive the call so the caller can
+			// still read the body; it is released when the body is closed.
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		apiErr := &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+		lastErr = classify(apiErr, resp.Header)
+		if resp.StatusCode < 500 || retry != retryIdempotent {
+			return nil, lastErr
+		}
+	}
+	return nil, lastErr
+}
+
+// isDialError reports whether err happened while connecting, before any of
+// the request was written
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// cancelOnClose releases a request context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff returns the jittered delay before the given retry attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Jitter in [delay/2, delay) spreads out concurrent retries.
+	half := delay / 2
+	return half + rand.N(delay-half)
+}
+
+// classify maps an error response to the matching typed error. signal-cli
+// reports most send failures as 400 with the cause in the message body.
+func classify(apiErr *APIError, header http.Header) error {
+	body := strings.ToLower(apiErr.Body)
+	switch {
+	case strings.Contains(body, "proofrequired") || strings.Contains(body, "proof required"):
+		e := &ProofRequiredError{Response: apiErr}
+		if m := proofTokenRe.FindStringSubmatch(apiErr.Body); m != nil {
+			e.Token = m[1]
+		}
+		return e
+	case apiErr.StatusCode == http.StatusTooManyRequests || strings.Contains(body, "ratelimit") || strings.Contains(body, "rate limit"):
+		e := &RateLimitError{Response: apiErr}
+		if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return e
+	case strings.Contains(body, "untrusted identity") || strings.Contains(body, "untrustedidentity"):
+		e := &UntrustedIdentityError{Response: apiErr}
+		if m := untrustedNumberRe.FindStringSubmatch(apiErr.Body); m != nil {
+			e.Number = m[1]
+		}
+		return e
+	case strings.Contains(body, "unregistered") || strings.Contains(body, "not registered"):
+		return &UnregisteredRecipientError{Response: apiErr}
+	}
+	return apiErr
+}
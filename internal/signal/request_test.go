@@ -0,0 +1,214 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func testClient(t *testing.T, handler http.HandlerFunc) *SignalClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := NewSignalClient(srv.URL, "+1234567890")
+	c.Retry = fastRetry
+	return c
+}
+
+func TestRequest_RetriesServerErrors(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[{"id":"group.abc"}]`))
+	})
+
+	groups, err := c.ListGroups(ctx)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("ListGroups() = %v, %v", groups, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRequest_GivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := c.ListGroups(ctx)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 {
+		t.Fatalf("expected APIError 500, got %v", err)
+	}
+	if calls != fastRetry.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", fastRetry.MaxAttempts, calls)
+	}
+}
+
+func TestRequest_NoRetryOnClientError(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Failed to send message: Unregistered user \"+1\""}`))
+	})
+
+	_, err := c.SendMessage(ctx, "+1", "hi")
+	var unregistered *UnregisteredRecipientError
+	if !errors.As(err, &unregistered) {
+		t.Fatalf("expected UnregisteredRecipientError, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestRequest_TypedErrors(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/reactions/+1234567890":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"ProofRequiredException: token=abc-123 options=[RECAPTCHA]"}`))
+		}
+	})
+
+	err := c.SendReaction(ctx, "+1", "👍", "+1", 1)
+	var rateLimited *RateLimitError
+	if !errors.As(err, &rateLimited) || rateLimited.RetryAfter != 30*time.Second {
+		t.Errorf("expected RateLimitError with 30s retry, got %v", err)
+	}
+
+	_, err = c.SendMessage(ctx, "+1", "hi")
+	var proof *ProofRequiredError
+	if !errors.As(err, &proof) || proof.Token != "abc-123" {
+		t.Errorf("expected ProofRequiredError with token, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("expected typed error to unwrap to APIError, got %v", err)
+	}
+}
+
+func TestRequest_UntrustedIdentity(t *testing.T) {
+	ctx := context.Background()
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Failed to send message: Untrusted Identity for \"+4915112345678\""}`))
+	})
+
+	_, err := c.SendMessage(ctx, "group.abc", "hi")
+	var untrusted *UntrustedIdentityError
+	if !errors.As(err, &untrusted) || untrusted.Number != "+4915112345678" {
+		t.Fatalf("expected UntrustedIdentityError for +4915112345678, got %#v", err)
+	}
+}
+
+func TestRequest_Unreachable(t *testing.T) {
+	ctx := context.Background()
+	c := NewSignalClient("http://127.0.0.1:1", "+1234567890")
+	c.Retry = fastRetry
+
+	_, err := c.ListGroups(ctx)
+	var unreachable *UnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("expected UnreachableError, got %v", err)
+	}
+}
+
+func TestRequest_AttemptTimeout(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			// The server only notices the client hanging up once the body is read.
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`[]`))
+	})
+	c.Timeouts.Control = 50 * time.Millisecond
+
+	if _, err := c.ListGroups(ctx); err != nil {
+		t.Fatalf("ListGroups() = %v; want retry after timed out attempt", err)
+	}
+}
+
+func TestRequest_SendNotRetried(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// signal-cli may still deliver a message after the client gave up.
+		if calls.Add(1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	c.Timeouts.Send = 50 * time.Millisecond
+
+	var unreachable *UnreachableError
+	if _, err := c.SendMessage(ctx, "+1", "hi"); !errors.As(err, &unreachable) {
+		t.Fatalf("expected UnreachableError after timeout, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected exactly 1 send after timeout, got %d", n)
+	}
+
+	var apiErr *APIError
+	if _, err := c.SendMessage(ctx, "+1", "hi"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected APIError 502, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected a 5xx send not to be retried, got %d requests", n)
+	}
+}
+
+func TestRequest_StopsWhenContextCancelled(t *testing.T) {
+	calls := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.Retry = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ListGroups(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt before cancellation, got %d", calls)
+	}
+}
+
+func TestNewHTTPClient_CapsConnections(t *testing.T) {
+	tr := NewHTTPClient(3).Transport.(*http.Transport)
+	if tr.MaxConnsPerHost != 3 || tr.MaxIdleConnsPerHost != 3 {
+		t.Fatalf("MaxConnsPerHost = %d, MaxIdleConnsPerHost = %d; want 3", tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost)
+	}
+}
@@ -1,51 +1,52 @@
 package signal
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 type SignalClient struct {
 	APIURL string
 	Number string
+	// Retry controls retries of requests that fail with a network error or 5xx
+	Retry RetryPolicy
+	// Timeouts bounds each attempt of a call by kind of operation
+	Timeouts Timeouts
+	// HTTPClient is shared by all calls so connections are pooled
+	HTTPClient *http.Client
+
+	avatarMu sync.Mutex
+	avatar   []byte // last avatar set by UpdateProfile, re-sent with later updates
 }
 
 func NewSignalClient(apiURL, number string) *SignalClient {
 	return &SignalClient{
-		APIURL: apiURL,
-		Number: number,
+		APIURL:     apiURL,
+		Number:     number,
+		Retry:      DefaultRetryPolicy,
+		Timeouts:   DefaultTimeouts,
+		HTTPClient: NewHTTPClient(0),
 	}
 }
 
 // ReceiveEvents fetches new events from the Signal REST API for the given number
-func (c *SignalClient) ReceiveEvents() ([]Envelope, error) {
+func (c *SignalClient) ReceiveEvents(ctx context.Context) ([]Envelope, error) {
 	fmt.Printf("[signal] Fetching events for %s from %s\n", c.Number, c.APIURL)
-	url := fmt.Sprintf("%s/v1/receive/%s", strings.TrimRight(c.APIURL, "/"), c.Number)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	bodyBytes, err := c.do(ctx, "GET", "/v1/receive/"+c.Number, nil, c.Timeouts.Receive, retryIdempotent)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("receive returned %d: %s", resp.StatusCode, string(body))
-	}
-	bodyBytes, _ := io.ReadAll(resp.Body)
 	if len(bodyBytes) == 0 {
 		return nil, nil
 	}
@@ -58,41 +59,51 @@ func (c *SignalClient) ReceiveEvents() ([]Envelope, error) {
 			return nil, fmt.Errorf("decode receive response: %v", err)
 		}
 	}
-	log.Printf("[signal] Received events for %s: %s\n", c.Number, string(bodyBytes))
 	var events []Envelope
 	for _, w := range wrappers {
+		logEnvelope(c.Number, w.Envelope)
 		events = append(events, w.Envelope)
 	}
 	return events, nil
 }
 
-// GetGroupPublicID fetches the public group ID for a given internal group ID
-func (c *SignalClient) GetGroupPublicID(internalGroupID string) (string, error) {
-	fmt.Printf("[signal] Looking up public group ID for internal ID: %s\n", internalGroupID)
-	url := fmt.Sprintf("%s/v1/groups/%s", strings.TrimRight(c.APIURL, "/"), c.Number)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
+// logEnvelope logs a received envelope, leaving out the content of
+// disappearing messages
+func logEnvelope(number string, ev Envelope) {
+	if secs := ev.ExpiresInSeconds(); secs > 0 {
+		from := ev.SourceNumber
+		if from == "" {
+			from = ev.SourceUUID
+		}
+		log.Printf("[signal] Received disappearing message for %s from %s at %d (content not logged, expires after %ds)\n",
+			number, from, ev.Timestamp, secs)
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	evb, _ := json.Marshal(ev)
+	log.Printf("[signal] Received event for %s: %s\n", number, string(evb))
+}
+
+// ListGroups fetches all groups the account is a member of from /v1/groups
+func (c *SignalClient) ListGroups(ctx context.Context) ([]Group, error) {
+	fmt.Printf("[signal] Listing groups for %s\n", c.Number)
+	body, err := c.do(ctx, "GET", "/v1/groups/"+c.Number, nil, c.Timeouts.Control, retryIdempotent)
 	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("groups returned %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
-	var groups []struct {
-		ID         string   `json:"id"`
-		InternalID string   `json:"internal_id"`
-		Name       string   `json:"name"`
-		Members    []string `json:"members"`
+	var groups []Group
+	if err := json.Unmarshal(body, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
-		return "", fmt.Errorf("failed to decode groups: %w", err)
+	return groups, nil
+}
+
+// GetGroupPublicID fetches the public group ID for a given internal group ID.
+// Prefer GroupDirectory, which caches the group list.
+func (c *SignalClient) GetGroupPublicID(ctx context.Context, internalGroupID string) (string, error) {
+	fmt.Printf("[signal] Looking up public group ID for internal ID: %s\n", internalGroupID)
+	groups, err := c.ListGroups(ctx)
+	if err != nil {
+		return "", err
 	}
 	for _, g := range groups {
 		if g.InternalID == internalGroupID && g.ID != "" {
@@ -102,121 +113,289 @@ func (c *SignalClient) GetGroupPublicID(internalGroupID string) (string, error)
 	return "", fmt.Errorf("public group id not found for internal id: %s", internalGroupID)
 }
 
-// SendMessage posts a message to /v2/send to the specified recipient
-func (c *SignalClient) SendMessage(to, message string) error {
-	return c.SendMessageWithQuote(to, message, nil)
+// SendMessage posts a message to /v2/send to the specified recipient and
+// returns the timestamp of the sent message
+func (c *SignalClient) SendMessage(ctx context.Context, to, message string) (int64, error) {
+	return c.SendMessageWithQuote(ctx, to, message, nil)
+}
+
+// SendMessageWithQuote posts a message to /v2/send with an optional quote and
+// returns the timestamp of the sent message
+func (c *SignalClient) SendMessageWithQuote(ctx context.Context, to, message string, quote *QuoteRequest) (int64, error) {
+	return c.Send(ctx, to, OutgoingMessage{Text: message, Quote: quote})
+}
+
+// SendEdit replaces the text of a message the bot sent earlier at
+// editTimestamp. The quote, if any, should match the original message.
+// It returns the timestamp of the edit itself.
+func (c *SignalClient) SendEdit(ctx context.Context, to, message string, editTimestamp int64, quote *QuoteRequest) (int64, error) {
+	return c.Send(ctx, to, OutgoingMessage{Text: message, Quote: quote, EditTimestamp: editTimestamp})
 }
 
-// SendMessageWithQuote posts a message to /v2/send with an optional quote
-func (c *SignalClient) SendMessageWithQuote(to, message string, quote *QuoteRequest) error {
-	fmt.Printf("[signal] Sending message to %s: %q\n", to, message)
+// SendFile posts a file attachment to /v2/send to the specified recipient and
+// returns the timestamp of the sent message
+func (c *SignalClient) SendFile(ctx context.Context, to, filePath, caption string) (int64, error) {
+	return c.SendFileWithQuote(ctx, to, filePath, caption, nil)
+}
+
+// SendFileWithQuote posts a file attachment to /v2/send with an optional quote
+// and returns the timestamp of the sent message. Use Send with ViewOnce set
+// for view-once media.
+func (c *SignalClient) SendFileWithQuote(ctx context.Context, to, filePath, caption string, quote *QuoteRequest) (int64, error) {
+	return c.Send(ctx, to, OutgoingMessage{Text: caption, Quote: quote, Attachments: []string{filePath}})
+}
+
+// SendSticker sends the sticker at stickerID in the installed pack packID,
+// with an optional quote, and returns the timestamp of the sent message
+func (c *SignalClient) SendSticker(ctx context.Context, to, packID string, stickerID int, quote *QuoteRequest) (int64, error) {
+	return c.Send(ctx, to, OutgoingMessage{Sticker: &Sticker{PackID: packID, StickerID: stickerID}, Quote: quote})
+}
+
+// Send posts m to /v2/send for the specified recipient and returns the
+// timestamp of the sent message
+func (c *SignalClient) Send(ctx context.Context, to string, m OutgoingMessage) (int64, error) {
+	// Content of disappearing messages is kept out of the logs.
+	logText := fmt.Sprintf("%q", m.Text)
+	if m.ExpiresInSeconds > 0 {
+		logText = fmt.Sprintf("[disappears after %ds]", m.ExpiresInSeconds)
+	}
+	if m.EditTimestamp != 0 {
+		fmt.Printf("[signal] Editing message %d for %s: %s\n", m.EditTimestamp, to, logText)
+	} else {
+		fmt.Printf("[signal] Sending message to %s: %s\n", to, logText)
+	}
 	payload := map[string]interface{}{
-		"message": message,
-		"number":  c.Number,
+		"number":     c.Number,
+		"recipients": []string{to},
 	}
-	payload["recipients"] = []string{to}
-
-	if quote != nil {
-		payload["quote_timestamp"] = quote.ID
-		payload["quote_author"] = quote.Author
-		payload["quote_message"] = quote.Text
-		fmt.Printf("[signal] Including quote from %s (id=%d): %q\n", quote.Author, quote.ID, quote.Text)
+	if m.Text != "" || len(m.Attachments) == 0 && m.Sticker == nil {
+		payload["message"] = m.Text
 	}
-
-	b, _ := json.Marshal(payload)
-	url := fmt.Sprintf("%s/v2/send", strings.TrimRight(c.APIURL, "/"))
-	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
-	if err != nil {
-		return err
+	if m.Sticker != nil {
+		payload["sticker"] = fmt.Sprintf("%s:%d", m.Sticker.PackID, m.Sticker.StickerID)
+		fmt.Printf("[signal] Including sticker %s:%d\n", m.Sticker.PackID, m.Sticker.StickerID)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	if p := m.LinkPreview; p != nil && strings.Contains(m.Text, p.URL) {
+		preview := map[string]interface{}{
+			"url":         p.URL,
+			"title":       p.Title,
+			"description": p.Description,
+		}
+		if len(p.Thumbnail) > 0 {
+			preview["base64_thumbnail"] = fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(p.Thumbnail),
+				base64.StdEncoding.EncodeToString(p.Thumbnail))
+		}
+		payload["link_preview"] = preview
+		if m.ExpiresInSeconds > 0 {
+			fmt.Printf("[signal] Including link preview [disappears after %ds]\n", m.ExpiresInSeconds)
+		} else {
+			fmt.Printf("[signal] Including link preview of %s\n", p.URL)
+		}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("send non-2xx: %d - %s", resp.StatusCode, string(body))
+	if m.Styled {
+		payload["text_mode"] = "styled"
+	}
+	if m.EditTimestamp != 0 {
+		payload["edit_timestamp"] = m.EditTimestamp
+	}
+	if len(m.Mentions) > 0 {
+		payload["mentions"] = m.Mentions
+	}
+	if m.ExpiresInSeconds > 0 {
+		payload["expires_in_seconds"] = m.ExpiresInSeconds
 	}
-	return nil
-}
-
-// SendFile posts a file attachment to /v2/send to the specified recipient
-func (c *SignalClient) SendFile(to, filePath, caption string) error {
-	return c.SendFileWithQuote(to, filePath, caption, nil)
-}
 
-// SendFileWithQuote posts a file attachment to /v2/send with an optional quote
-func (c *SignalClient) SendFileWithQuote(to, filePath, caption string, quote *QuoteRequest) error {
-	fmt.Printf("[signal] Sending file %s to %s\n", filePath, to)
+	if m.Quote != nil {
+		payload["quote_timestamp"] = m.Quote.ID
+		payload["quote_author"] = m.Quote.Author
+		payload["quote_message"] = m.Quote.Text
+		fmt.Printf("[signal] Including quote from %s (id=%d)\n", m.Quote.Author, m.Quote.ID)
+	}
 
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	timeout := c.Timeouts.Send
+	if len(m.Attachments) > 0 {
+		attachments := make([]string, 0, len(m.Attachments))
+		for _, path := range m.Attachments {
+			if m.ExpiresInSeconds > 0 {
+				fmt.Printf("[signal] Attaching file [disappears after %ds]\n", m.ExpiresInSeconds)
+			} else {
+				fmt.Printf("[signal] Attaching file %s\n", path)
+			}
+			dataURI, err := attachmentDataURI(path)
+			if err != nil {
+				return 0, err
+			}
+			attachments = append(attachments, dataURI)
+		}
+		payload["base64_attachments"] = attachments
+		if m.ViewOnce {
+			payload["view_once"] = true
+		}
+		timeout = c.Timeouts.Upload
 	}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	var mimeType string
-	switch ext {
+	return c.send(ctx, payload, timeout)
+}
+
+// ContentType returns the MIME type attachments with the file's extension are sent as
+func ContentType(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
 	case ".mp4":
-		mimeType = "video/mp4"
+		return "video/mp4"
 	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
+		return "image/jpeg"
 	case ".png":
-		mimeType = "image/png"
+		return "image/png"
 	case ".gif":
-		mimeType = "image/gif"
-	default:
-		mimeType = "application/octet-stream"
+		return "image/gif"
+	case ".txt":
+		return "text/plain"
+	case ".md":
+		return "text/markdown"
+	}
+	return "application/octet-stream"
+}
+
+// attachmentDataURI reads a local file and encodes it as a data URI for base64_attachments
+func attachmentDataURI(filePath string) (string, error) {
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
+	mimeType := ContentType(filePath)
 	base64Content := base64.StdEncoding.EncodeToString(fileContent)
 
 	filename := filepath.Base(filePath)
-	dataURI := fmt.Sprintf("data:%s;filename=%s;base64,%s", mimeType, filename, base64Content)
+	return fmt.Sprintf("data:%s;filename=%s;base64,%s", mimeType, filename, base64Content), nil
+}
 
-	payload := map[string]interface{}{
-		"number":             c.Number,
-		"recipients":         []string{to},
-		"base64_attachments": []string{dataURI},
+// send posts payload to /v2/send and returns the timestamp of the sent
+// message. The message was sent even when the timestamp is zero: the REST API
+// accepted it but did not report one.
+func (c *SignalClient) send(ctx context.Context, payload map[string]interface{}, timeout time.Duration) (int64, error) {
+	body, err := c.do(ctx, "POST", "/v2/send", payload, timeout, retryBeforeSend)
+	if err != nil {
+		return 0, err
 	}
 
-	if caption != "" {
-		payload["message"] = caption
+	// The REST API reports the timestamp as a string, older versions as a number.
+	var sent struct {
+		Timestamp json.Number `json:"timestamp"`
+	}
+	if err := json.Unmarshal(body, &sent); err != nil || sent.Timestamp == "" {
+		fmt.Printf("[signal] Send response has no timestamp: %s\n", string(body))
+		return 0, nil
+	}
+	ts, err := sent.Timestamp.Int64()
+	if err != nil {
+		fmt.Printf("[signal] Send response has an invalid timestamp: %s\n", string(body))
+		return 0, nil
 	}
+	return ts, nil
+}
+
+// StartTyping shows a typing indicator to the recipient via /v1/typing-indicator
+func (c *SignalClient) StartTyping(ctx context.Context, to string) error {
+	return c.setTypingIndicator(ctx, "PUT", to)
+}
+
+// StopTyping hides the typing indicator previously shown to the recipient
+func (c *SignalClient) StopTyping(ctx context.Context, to string) error {
+	return c.setTypingIndicator(ctx, "DELETE", to)
+}
 
-	if quote != nil {
-		payload["quote_timestamp"] = quote.ID
-		payload["quote_author"] = quote.Author
-		payload["quote_message"] = quote.Text
-		fmt.Printf("[signal] Including quote from %s (id=%d): %q\n", quote.Author, quote.ID, quote.Text)
+func (c *SignalClient) setTypingIndicator(ctx context.Context, method, to string) error {
+	payload := map[string]interface{}{"recipient": to}
+	_, err := c.do(ctx, method, "/v1/typing-indicator/"+c.Number, payload, c.Timeouts.Control, retryIdempotent)
+	return err
+}
+
+// SendReaction reacts with emoji to the message sent by targetAuthor at targetTimestamp
+func (c *SignalClient) SendReaction(ctx context.Context, to, emoji, targetAuthor string, targetTimestamp int64) error {
+	return c.setReaction(ctx, "POST", to, emoji, targetAuthor, targetTimestamp)
+}
+
+// RemoveReaction removes a reaction previously sent with SendReaction
+func (c *SignalClient) RemoveReaction(ctx context.Context, to, emoji, targetAuthor string, targetTimestamp int64) error {
+	return c.setReaction(ctx, "DELETE", to, emoji, targetAuthor, targetTimestamp)
+}
+
+func (c *SignalClient) setReaction(ctx context.Context, method, to, emoji, targetAuthor string, targetTimestamp int64) error {
+	fmt.Printf("[signal] Reacting %s to %s (author=%s, ts=%d)\n", emoji, to, targetAuthor, targetTimestamp)
+	payload := map[string]interface{}{
+		"recipient":     to,
+		"reaction":      emoji,
+		"target_author": targetAuthor,
+		"timestamp":     targetTimestamp,
 	}
+	_, err := c.do(ctx, method, "/v1/reactions/"+c.Number, payload, c.Timeouts.Control, retryBeforeSend)
+	return err
+}
 
-	jsonData, err := json.Marshal(payload)
+// GetAttachment downloads an attachment from /v1/attachments/{id} into a temp
+// file and returns its path. Downloads larger than maxBytes are rejected; a
+// maxBytes of zero or less disables the cap. The caller removes the file.
+func (c *SignalClient) GetAttachment(ctx context.Context, id string, maxBytes int64) (string, error) {
+	fmt.Printf("[signal] Fetching attachment %s\n", id)
+	resp, err := c.request(ctx, "GET", "/v1/attachments/"+url.PathEscape(id), nil, c.Timeouts.Upload, retryIdempotent)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return "", fmt.Errorf("attachment %s is %d bytes, limit is %d", id, resp.ContentLength, maxBytes)
 	}
 
-	url := fmt.Sprintf("%s/v2/send", strings.TrimRight(c.APIURL, "/"))
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
+	f, err := os.CreateTemp("", "signal-attachment-*"+filepath.Ext(id))
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		// Read one byte past the cap so an oversized body without a
+		// Content-Length is still detected.
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && maxBytes > 0 && n > maxBytes {
+		err = fmt.Errorf("attachment %s exceeds limit of %d bytes", id, maxBytes)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		os.Remove(f.Name())
+		return "", err
 	}
-	defer resp.Body.Close()
+	return f.Name(), nil
+}
 
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("send file non-2xx: %d - %s", resp.StatusCode, string(body))
+// RemoteDelete deletes the bot's own message sent at timestamp for everyone in the chat
+func (c *SignalClient) RemoteDelete(ctx context.Context, to string, timestamp int64) error {
+	fmt.Printf("[signal] Deleting message %d for %s\n", timestamp, to)
+	payload := map[string]interface{}{
+		"recipient": to,
+		"timestamp": timestamp,
 	}
+	_, err := c.do(ctx, "DELETE", "/v1/remote-delete/"+c.Number, payload, c.Timeouts.Control, retryBeforeSend)
+	return err
+}
+
+// Receipt types accepted by SendReceipt
+const (
+	ReceiptRead   = "read"
+	ReceiptViewed = "viewed"
+)
 
-	return nil
+// SendReceipt tells the sender of the message at timestamp that it was read or viewed
+func (c *SignalClient) SendReceipt(ctx context.Context, to, receiptType string, timestamp int64) error {
+	fmt.Printf("[signal] Sending %s receipt to %s for %d\n", receiptType, to, timestamp)
+	payload := map[string]interface{}{
+		"recipient":    to,
+		"receipt_type": receiptType,
+		"timestamp":    timestamp,
+	}
+	_, err := c.do(ctx, "POST", "/v1/receipts/"+c.Number, payload, c.Timeouts.Control, retryBeforeSend)
+	return err
 }
@@ -0,0 +1,57 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSendSticker_Payload(t *testing.T) {
+	var payload map[string]interface{}
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"timestamp":"42"}`))
+	})
+
+	ts, err := c.SendSticker(context.Background(), "+1", "8f1b2c", 4, nil)
+	if err != nil || ts != 42 {
+		t.Fatalf("SendSticker() = %d, %v", ts, err)
+	}
+	if payload["sticker"] != "8f1b2c:4" {
+		t.Errorf("sticker = %v, want %q", payload["sticker"], "8f1b2c:4")
+	}
+	if _, ok := payload["message"]; ok {
+		t.Errorf("sticker sent with a message field: %v", payload)
+	}
+}
+
+func TestSend_MissingTimestamp(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	})
+
+	ts, err := c.SendMessage(context.Background(), "+1", "hi")
+	if err != nil || ts != 0 {
+		t.Fatalf("SendMessage() = %d, %v; want a sent message with unknown timestamp", ts, err)
+	}
+}
+
+func TestGetAttachment_EscapesID(t *testing.T) {
+	var path string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.Write([]byte("data"))
+	})
+
+	file, err := c.GetAttachment(context.Background(), "../receive/+1234567890", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(file)
+	if path != "/v1/attachments/..%2Freceive%2F+1234567890" {
+		t.Errorf("requested %q", path)
+	}
+}
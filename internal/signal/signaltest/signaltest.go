@@ -0,0 +1,314 @@
+// Package signaltest provides an in-memory signal.Transport for tests.
+package signaltest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/afeedhshaji/signal-llm-bot/internal/signal"
+)
+
+// Sent is an outbound message recorded by Transport
+type Sent struct {
+	To        string
+	Message   signal.OutgoingMessage
+	Timestamp int64
+}
+
+// Reaction is an outbound reaction recorded by Transport
+type Reaction struct {
+	To              string
+	Emoji           string
+	TargetAuthor    string
+	TargetTimestamp int64
+	Removed         bool
+}
+
+// Receipt is an outbound receipt recorded by Transport
+type Receipt struct {
+	To        string
+	Type      string
+	Timestamp int64
+}
+
+// Transport is an in-memory signal.Transport. Envelopes passed to Inject are
+// handed out by ReceiveEvents and StreamEvents; everything sent is recorded.
+type Transport struct {
+	mu       sync.Mutex
+	inbox    []signal.Envelope
+	notify   chan struct{}
+	nextTS   int64
+	sent     []Sent
+	reacts   []Reaction
+	receipts []Receipt
+	deleted  []int64
+	typing   map[string]bool
+	profiles []signal.Profile
+	trusted  []string
+
+	// Groups is returned by ListGroups
+	Groups []signal.Group
+	// Attachments maps attachment IDs to the content GetAttachment serves
+	Attachments map[string][]byte
+	// SendErr, when set, is returned by every send except reactions, so that
+	// a failed reply can still be acknowledged
+	SendErr error
+	// NoTimestamps makes sends succeed without reporting a timestamp, like
+	// REST API versions whose send response lacks one
+	NoTimestamps bool
+	// UUID is returned by SelfUUID; when empty SelfUUID fails
+	UUID string
+	// Identities is returned by ListIdentities
+	Identities []signal.Identity
+	// Untrusted holds recipients whose sends fail with an
+	// UntrustedIdentityError until TrustIdentity is called for them
+	Untrusted map[string]bool
+}
+
+// New creates an empty Transport
+func New() *Transport {
+	return &Transport{
+		notify:      make(chan struct{}, 1),
+		nextTS:      1000,
+		typing:      make(map[string]bool),
+		Attachments: make(map[string][]byte),
+		Untrusted:   make(map[string]bool),
+	}
+}
+
+var _ signal.Transport = (*Transport)(nil)
+
+// Inject queues envelopes as if they were received from Signal
+func (t *Transport) Inject(envelopes ...signal.Envelope) {
+	t.mu.Lock()
+	t.inbox = append(t.inbox, envelopes...)
+	t.mu.Unlock()
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Sent returns every message sent so far
+func (t *Transport) Sent() []Sent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Sent(nil), t.sent...)
+}
+
+// Reactions returns every reaction sent or removed so far
+func (t *Transport) Reactions() []Reaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Reaction(nil), t.reacts...)
+}
+
+// Receipts returns every receipt sent so far
+func (t *Transport) Receipts() []Receipt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Receipt(nil), t.receipts...)
+}
+
+// Deleted returns the timestamps of remotely deleted messages
+func (t *Transport) Deleted() []int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]int64(nil), t.deleted...)
+}
+
+// Profiles returns every profile update so far
+func (t *Transport) Profiles() []signal.Profile {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]signal.Profile(nil), t.profiles...)
+}
+
+// Trusted returns the numbers passed to TrustIdentity so far
+func (t *Transport) Trusted() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.trusted...)
+}
+
+// Typing reports whether a typing indicator is currently shown to the recipient
+func (t *Transport) Typing(to string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.typing[to]
+}
+
+// ReceiveEvents drains the injected envelopes
+func (t *Transport) ReceiveEvents(ctx context.Context) ([]signal.Envelope, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.inbox
+	t.inbox = nil
+	return events, nil
+}
+
+// StreamEvents hands injected envelopes to handle until ctx is cancelled
+func (t *Transport) StreamEvents(ctx context.Context, handle func(signal.Envelope)) error {
+	for {
+		events, _ := t.ReceiveEvents(ctx)
+		for _, ev := range events {
+			handle(ev)
+		}
+		select {
+		case <-t.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Send records m and returns a fresh timestamp, or zero with NoTimestamps
+func (t *Transport) Send(ctx context.Context, to string, m signal.OutgoingMessage) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.SendErr != nil {
+		return 0, t.SendErr
+	}
+	if t.Untrusted[to] {
+		return 0, &signal.UntrustedIdentityError{Number: to, Response: &signal.APIError{
+			Method: "POST", Path: "/v2/send", StatusCode: 400, Body: "Untrusted Identity for \"" + to + "\"",
+		}}
+	}
+	t.nextTS++
+	t.sent = append(t.sent, Sent{To: to, Message: m, Timestamp: t.nextTS})
+	if t.NoTimestamps {
+		return 0, nil
+	}
+	return t.nextTS, nil
+}
+
+// SendFileWithQuote records the file as an attachment of a sent message
+func (t *Transport) SendFileWithQuote(ctx context.Context, to, filePath, caption string, quote *signal.QuoteRequest) (int64, error) {
+	return t.Send(ctx, to, signal.OutgoingMessage{Text: caption, Quote: quote, Attachments: []string{filePath}})
+}
+
+// SendSticker records the sticker as a sent message
+func (t *Transport) SendSticker(ctx context.Context, to, packID string, stickerID int, quote *signal.QuoteRequest) (int64, error) {
+	return t.Send(ctx, to, signal.OutgoingMessage{Sticker: &signal.Sticker{PackID: packID, StickerID: stickerID}, Quote: quote})
+}
+
+// SendReaction records the reaction
+func (t *Transport) SendReaction(ctx context.Context, to, emoji, targetAuthor string, targetTimestamp int64) error {
+	return t.react(Reaction{To: to, Emoji: emoji, TargetAuthor: targetAuthor, TargetTimestamp: targetTimestamp})
+}
+
+// RemoveReaction records the removal of a reaction
+func (t *Transport) RemoveReaction(ctx context.Context, to, emoji, targetAuthor string, targetTimestamp int64) error {
+	return t.react(Reaction{To: to, Emoji: emoji, TargetAuthor: targetAuthor, TargetTimestamp: targetTimestamp, Removed: true})
+}
+
+func (t *Transport) react(r Reaction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reacts = append(t.reacts, r)
+	return nil
+}
+
+// SendReceipt records the receipt
+func (t *Transport) SendReceipt(ctx context.Context, to, receiptType string, timestamp int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.receipts = append(t.receipts, Receipt{To: to, Type: receiptType, Timestamp: timestamp})
+	return nil
+}
+
+// StartTyping marks the recipient as seeing a typing indicator
+func (t *Transport) StartTyping(ctx context.Context, to string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.typing[to] = true
+	return nil
+}
+
+// StopTyping clears the recipient's typing indicator
+func (t *Transport) StopTyping(ctx context.Context, to string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.typing, to)
+	return nil
+}
+
+// RemoteDelete records the deletion
+func (t *Transport) RemoteDelete(ctx context.Context, to string, timestamp int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.SendErr != nil {
+		return t.SendErr
+	}
+	t.deleted = append(t.deleted, timestamp)
+	return nil
+}
+
+// UpdateProfile records the profile update
+func (t *Transport) UpdateProfile(ctx context.Context, p signal.Profile) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.SendErr != nil {
+		return t.SendErr
+	}
+	t.profiles = append(t.profiles, p)
+	return nil
+}
+
+// ListGroups returns Groups
+func (t *Transport) ListGroups(ctx context.Context) ([]signal.Group, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]signal.Group(nil), t.Groups...), nil
+}
+
+// GetAttachment writes the content registered in Attachments to a temp file
+func (t *Transport) GetAttachment(ctx context.Context, id string, maxBytes int64) (string, error) {
+	t.mu.Lock()
+	content, ok := t.Attachments[id]
+	t.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("attachment %s not found", id)
+	}
+	if maxBytes > 0 && int64(len(content)) > maxBytes {
+		return "", fmt.Errorf("attachment %s exceeds limit of %d bytes", id, maxBytes)
+	}
+	f, err := os.CreateTemp("", "signaltest-attachment-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// SelfUUID returns UUID
+func (t *Transport) SelfUUID(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.UUID == "" {
+		return "", fmt.Errorf("uuid not known")
+	}
+	return t.UUID, nil
+}
+
+// ListIdentities returns Identities
+func (t *Transport) ListIdentities(ctx context.Context) ([]signal.Identity, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]signal.Identity(nil), t.Identities...), nil
+}
+
+// TrustIdentity records the number and lets sends to it succeed again
+func (t *Transport) TrustIdentity(ctx context.Context, number, safetyNumber string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trusted = append(t.trusted, number)
+	delete(t.Untrusted, number)
+	return nil
+}
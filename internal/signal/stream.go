@@ -0,0 +1,135 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamMinBackoff = 1 * time.Second
+	streamMaxBackoff = 30 * time.Second
+)
+
+// The receive stream is pinged every streamPingInterval. A connection that
+// delivers neither a frame nor a pong for streamReadTimeout is treated as
+// dropped, so connections a NAT or proxy closed silently are re-established.
+var (
+	streamPingInterval = 30 * time.Second
+	streamReadTimeout  = 75 * time.Second
+)
+
+// StreamEvents holds a websocket open to /v1/receive/{number} (signal-cli-rest-api
+// in json-rpc mode) and calls handle for every envelope as it arrives. Dropped
+// connections are re-established with exponential backoff. It returns when ctx
+// is cancelled.
+func (c *SignalClient) StreamEvents(ctx context.Context, handle func(Envelope)) error {
+	backoff := streamMinBackoff
+	for {
+		connected, err := c.streamOnce(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = streamMinBackoff
+		}
+		log.Printf("[signal] Receive stream for %s closed: %v (reconnecting in %s)", c.Number, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > streamMaxBackoff {
+			backoff = streamMaxBackoff
+		}
+	}
+}
+
+// streamOnce runs a single websocket session and reports whether the
+// connection was established before it ended.
+func (c *SignalClient) streamOnce(ctx context.Context, handle func(Envelope)) (bool, error) {
+	url, err := c.receiveStreamURL()
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("[signal] Opening receive stream for %s at %s\n", c.Number, url)
+
+	dialer := websocket.Dialer{HandshakeTimeout: c.Timeouts.Control, Proxy: http.ProxyFromEnvironment}
+	conn, resp, err := dialer.DialContext(ctx, url, http.Header{})
+	if err != nil {
+		if resp != nil {
+			return false, fmt.Errorf("websocket dial returned %d: %w", resp.StatusCode, err)
+		}
+		return false, err
+	}
+	defer conn.Close()
+
+	ping, readTimeout := streamPingInterval, streamReadTimeout
+	alive := func() error { return conn.SetReadDeadline(time.Now().Add(readTimeout)) }
+	alive()
+	conn.SetPongHandler(func(string) error { return alive() })
+
+	// Keep the connection alive, and unblock ReadMessage when the context is
+	// cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(ping)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// A failed ping shows up as a read timeout.
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ping))
+			case <-ctx.Done():
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(time.Second))
+				conn.Close()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		alive()
+		if len(data) == 0 {
+			continue
+		}
+		var w EnvelopeWrapper
+		if err := json.Unmarshal(data, &w); err != nil {
+			log.Printf("[signal] Skipping undecodable frame: %v", err)
+			continue
+		}
+		logEnvelope(c.Number, w.Envelope)
+		handle(w.Envelope)
+	}
+}
+
+// receiveStreamURL converts the configured API URL into the websocket receive URL
+func (c *SignalClient) receiveStreamURL() (string, error) {
+	base := strings.TrimRight(c.APIURL, "/")
+	switch {
+	case strings.HasPrefix(base, "https://"):
+		base = "wss://" + strings.TrimPrefix(base, "https://")
+	case strings.HasPrefix(base, "http://"):
+		base = "ws://" + strings.TrimPrefix(base, "http://")
+	case strings.HasPrefix(base, "ws://"), strings.HasPrefix(base, "wss://"):
+	default:
+		return "", fmt.Errorf("unsupported signal api url scheme: %s", c.APIURL)
+	}
+	return fmt.Sprintf("%s/v1/receive/%s", base, c.Number), nil
+}
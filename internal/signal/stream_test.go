@@ -0,0 +1,122 @@
+package signal
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamServer serves /v1/receive over a websocket, running session with the
+// number of the connection, starting at 1
+func streamServer(t *testing.T, session func(n int32, conn *websocket.Conn)) (*SignalClient, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	upgrader := websocket.Upgrader{}
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/receive/+1234567890" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		session(conns.Add(1), conn)
+	})
+	return c, &conns
+}
+
+// collect streams envelopes until want have arrived, then stops the stream
+func collect(t *testing.T, c *SignalClient, want int) []Envelope {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []Envelope
+	err := c.StreamEvents(ctx, func(ev Envelope) {
+		got = append(got, ev)
+		if len(got) == want {
+			cancel()
+		}
+	})
+	if len(got) != want {
+		t.Fatalf("received %d envelopes before %v, want %d", len(got), err, want)
+	}
+	return got
+}
+
+func TestStreamEvents_DecodesFramesAndReconnects(t *testing.T) {
+	c, conns := streamServer(t, func(n int32, conn *websocket.Conn) {
+		if n == 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"envelope":{"sourceNumber":"+1","timestamp":1,"dataMessage":{"message":"first"}}}`))
+			conn.WriteMessage(websocket.TextMessage, nil)
+			conn.WriteMessage(websocket.TextMessage, []byte(`not json`))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart"))
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"envelope":{"sourceNumber":"+2","timestamp":2,"dataMessage":{"message":"second"}}}`))
+		conn.ReadMessage()
+	})
+
+	got := collect(t, c, 2)
+	if got[0].SourceNumber != "+1" || got[0].DataMessage == nil || got[0].DataMessage.Message != "first" {
+		t.Errorf("unexpected first envelope %+v", got[0])
+	}
+	if got[1].SourceNumber != "+2" || got[1].Timestamp != 2 {
+		t.Errorf("unexpected second envelope %+v", got[1])
+	}
+	if n := conns.Load(); n != 2 {
+		t.Errorf("expected one reconnect, got %d connections", n)
+	}
+}
+
+func TestStreamEvents_ReconnectsSilentConnection(t *testing.T) {
+	defer func(ping, read time.Duration) {
+		streamPingInterval, streamReadTimeout = ping, read
+	}(streamPingInterval, streamReadTimeout)
+	streamPingInterval, streamReadTimeout = time.Hour, 100*time.Millisecond
+
+	c, conns := streamServer(t, func(n int32, conn *websocket.Conn) {
+		if n == 1 {
+			// Stay open but never send anything, like a dropped connection.
+			time.Sleep(time.Second)
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"envelope":{"sourceNumber":"+2","timestamp":2}}`))
+		conn.ReadMessage()
+	})
+
+	collect(t, c, 1)
+	if n := conns.Load(); n != 2 {
+		t.Errorf("expected the silent connection to be replaced, got %d connections", n)
+	}
+}
+
+func TestStreamEvents_PingsKeepIdleConnection(t *testing.T) {
+	defer func(ping, read time.Duration) {
+		streamPingInterval, streamReadTimeout = ping, read
+	}(streamPingInterval, streamReadTimeout)
+	streamPingInterval, streamReadTimeout = 20*time.Millisecond, 100*time.Millisecond
+
+	c, conns := streamServer(t, func(n int32, conn *websocket.Conn) {
+		// Reading answers the client's pings with pongs.
+		go func() {
+			time.Sleep(400 * time.Millisecond)
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"envelope":{"sourceNumber":"+1","timestamp":1}}`))
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	collect(t, c, 1)
+	if n := conns.Load(); n != 1 {
+		t.Errorf("idle connection answering pings was dropped, got %d connections", n)
+	}
+}
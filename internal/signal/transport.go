@@ -0,0 +1,32 @@
+package signal
+
+import "context"
+
+// Transport is everything the bot needs from the Signal REST API. SignalClient
+// implements it against signal-cli-rest-api; signaltest.Transport implements
+// it in memory for tests.
+type Transport interface {
+	// Receiving
+	ReceiveEvents(ctx context.Context) ([]Envelope, error)
+	StreamEvents(ctx context.Context, handle func(Envelope)) error
+
+	// Sending
+	Send(ctx context.Context, to string, m OutgoingMessage) (int64, error)
+	SendFileWithQuote(ctx context.Context, to, filePath, caption string, quote *QuoteRequest) (int64, error)
+	SendSticker(ctx context.Context, to, packID string, stickerID int, quote *QuoteRequest) (int64, error)
+	SendReaction(ctx context.Context, to, emoji, targetAuthor string, targetTimestamp int64) error
+	SendReceipt(ctx context.Context, to, receiptType string, timestamp int64) error
+	StartTyping(ctx context.Context, to string) error
+	StopTyping(ctx context.Context, to string) error
+	RemoteDelete(ctx context.Context, to string, timestamp int64) error
+	UpdateProfile(ctx context.Context, p Profile) error
+	TrustIdentity(ctx context.Context, number, safetyNumber string) error
+
+	// Lookups
+	ListGroups(ctx context.Context) ([]Group, error)
+	GetAttachment(ctx context.Context, id string, maxBytes int64) (string, error)
+	SelfUUID(ctx context.Context) (string, error)
+	ListIdentities(ctx context.Context) ([]Identity, error)
+}
+
+var _ Transport = (*SignalClient)(nil)
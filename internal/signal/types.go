@@ -12,19 +12,110 @@ type Envelope struct {
 	SourceUUID   string       `json:"sourceUuid"`
 	Timestamp    int64        `json:"timestamp"`
 	DataMessage  *DataMessage `json:"dataMessage"`
+	EditMessage  *EditMessage `json:"editMessage"`
+	SyncMessage  *SyncMessage `json:"syncMessage"`
+}
+
+// SyncMessage carries activity from other devices linked to the bot's account
+type SyncMessage struct {
+	SentMessage *SentMessage `json:"sentMessage"`
+}
+
+// SentMessage is a message sent by a linked device of the bot's account. The
+// destination is empty for group messages.
+type SentMessage struct {
+	DataMessage
+	Destination       string `json:"destination"`
+	DestinationNumber string `json:"destinationNumber"`
+	DestinationUUID   string `json:"destinationUuid"`
+	Timestamp         int64  `json:"timestamp"`
+}
+
+// EditMessage replaces the content of the message sent at TargetSentTimestamp
+type EditMessage struct {
+	TargetSentTimestamp int64        `json:"targetSentTimestamp"`
+	DataMessage         *DataMessage `json:"dataMessage"`
 }
 
 type DataMessage struct {
-	Message   string     `json:"message"`
-	Mentions  []Mention  `json:"mentions"`
-	GroupInfo *GroupInfo `json:"groupInfo"`
-	Quote     *Quote     `json:"quote"`
+	Message     string       `json:"message"`
+	Mentions    []Mention    `json:"mentions"`
+	GroupInfo   *GroupInfo   `json:"groupInfo"`
+	Quote       *Quote       `json:"quote"`
+	Reaction    *Reaction    `json:"reaction"`
+	Attachments []Attachment `json:"attachments"`
+	// ExpiresInSeconds is the chat's disappearing-message timer; zero when off
+	ExpiresInSeconds int64 `json:"expiresInSeconds"`
+	// ViewOnce marks the attachments as view-once media
+	ViewOnce bool     `json:"viewOnce"`
+	Sticker  *Sticker `json:"sticker"`
+}
+
+// Sticker identifies a sticker by its pack and position in the pack. Emoji is
+// the emoji the sticker stands for, when known.
+type Sticker struct {
+	PackID    string `json:"packId"`
+	StickerID int    `json:"stickerId"`
+	Emoji     string `json:"emoji"`
+}
+
+// ExpiresInSeconds returns the disappearing-message timer of whichever
+// message the envelope carries, or zero
+func (e *Envelope) ExpiresInSeconds() int64 {
+	switch {
+	case e.DataMessage != nil:
+		return e.DataMessage.ExpiresInSeconds
+	case e.EditMessage != nil && e.EditMessage.DataMessage != nil:
+		return e.EditMessage.DataMessage.ExpiresInSeconds
+	case e.SyncMessage != nil && e.SyncMessage.SentMessage != nil:
+		return e.SyncMessage.SentMessage.ExpiresInSeconds
+	}
+	return 0
 }
 
 type GroupInfo struct {
 	GroupID string `json:"groupId"`
 }
 
+// Group is an entry of the /v1/groups listing. ID is the public ID used to
+// send to the group; InternalID matches GroupInfo.GroupID on incoming messages.
+type Group struct {
+	ID          string   `json:"id"`
+	InternalID  string   `json:"internal_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Members     []string `json:"members"`
+	Admins      []string `json:"admins"`
+	Blocked     bool     `json:"blocked"`
+}
+
+// Contact is an entry of the /v1/contacts listing
+type Contact struct {
+	Number      string `json:"number"`
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	ProfileName string `json:"profile_name"`
+}
+
+// Identity is an entry of the /v1/identities listing: the identity key
+// Signal knows for a contact and whether it is trusted
+type Identity struct {
+	Number       string `json:"number"`
+	UUID         string `json:"uuid"`
+	Status       string `json:"status"`
+	Fingerprint  string `json:"fingerprint"`
+	SafetyNumber string `json:"safety_number"`
+	Added        string `json:"added"`
+}
+
+// Profile is the account's public Signal profile. AvatarPath is a local image
+// file; see SignalClient.UpdateProfile for what an empty AvatarPath does.
+type Profile struct {
+	Name       string
+	About      string
+	AvatarPath string
+}
+
 type Mention struct {
 	Start  int    `json:"start"`
 	Length int    `json:"length"`
@@ -39,9 +130,74 @@ type Quote struct {
 	Text       string `json:"text"`
 }
 
+// Attachment describes a file sent with a message; the content is fetched
+// separately with SignalClient.GetAttachment
+type Attachment struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Caption     string `json:"caption"`
+}
+
+// Reaction is an emoji reaction to a previously sent message
+type Reaction struct {
+	Emoji               string `json:"emoji"`
+	TargetAuthor        string `json:"targetAuthor"`
+	TargetAuthorNumber  string `json:"targetAuthorNumber"`
+	TargetAuthorUUID    string `json:"targetAuthorUuid"`
+	TargetSentTimestamp int64  `json:"targetSentTimestamp"`
+	IsRemove            bool   `json:"isRemove"`
+}
+
 // QuoteRequest represents a quote to include when sending a message
 type QuoteRequest struct {
 	ID     int64  `json:"id"`
 	Author string `json:"author"`
 	Text   string `json:"text"`
 }
+
+// MentionRequest mentions author over a range of an outgoing message. Start
+// and Length are measured in UTF-16 code units.
+type MentionRequest struct {
+	Author string `json:"author"`
+	Start  int    `json:"start"`
+	Length int    `json:"length"`
+}
+
+// OutgoingMessage describes a message sent through SignalClient.Send
+type OutgoingMessage struct {
+	Text  string
+	Quote *QuoteRequest
+	// Styled sends Text with text_mode "styled", so signal-cli renders
+	// **bold**, *italic*, ~strikethrough~ and `monospace` markers
+	Styled bool
+	// Attachments are local file paths sent as base64 attachments
+	Attachments []string
+	// Mentions reference placeholder ranges in Text; see MentionAt
+	Mentions []MentionRequest
+	// EditTimestamp, when set, replaces the bot's earlier message sent at that timestamp
+	EditTimestamp int64
+	// ExpiresInSeconds makes the message disappear after that long, matching
+	// the chat's timer. signal-cli applies the timer it has stored for the
+	// chat as well; this keeps replies in step when that is out of date.
+	ExpiresInSeconds int64
+	// ViewOnce lets recipients open the attachments only once. Signal clients
+	// honour it for images and videos.
+	ViewOnce bool
+	// Sticker, when set, sends a sticker from an installed pack instead of text
+	Sticker *Sticker
+	// LinkPreview is shown for a link in Text; its URL must appear in Text
+	LinkPreview *LinkPreview
+}
+
+// LinkPreview describes a link in a sent message. Signal clients do not fetch
+// previews of received links, so the sender supplies them.
+type LinkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	Thumbnail   []byte // image data, optional
+}
@@ -31,9 +31,12 @@ type GraphQLResponse struct {
 	} `json:"data"`
 }
 
-// DownloadInstagramVideo downloads a video from Instagram URL using GraphQL API
-func DownloadInstagramVideo(instaURL string) *DownloadResult {
-	log.Printf("Starting Instagram download for: %s", instaURL)
+// DownloadInstagramVideo downloads a video from Instagram URL using GraphQL API.
+// With redact set nothing identifying the post is logged, for links from
+// disappearing chats.
+func DownloadInstagramVideo(instaURL string, redact bool) *DownloadResult {
+	logf := logger(redact)
+	logf("Starting Instagram download for: %s", instaURL)
 
 	shortcode, err := extractShortcode(instaURL)
 	if err != nil {
@@ -45,33 +48,41 @@ func DownloadInstagramVideo(instaURL string) *DownloadResult {
 		Success: false,
 	}
 
-	videoURL, err := fetchVideoInfoFromGraphQL(shortcode)
+	videoURL, err := fetchVideoInfoFromGraphQL(shortcode, logf)
 	if err != nil {
-		log.Printf("Error fetching video info: %v", err)
+		logf("Error fetching video info: %v", err)
 		result.Error = err
 		return result
 	}
 
-	log.Printf("Got video URL: %s", videoURL)
+	logf("Got video URL: %s", videoURL)
 
 	outputFile := fmt.Sprintf("%s.mp4", shortcode)
-	if err := downloadFile(videoURL, outputFile); err != nil {
-		log.Printf("Error downloading video: %v", err)
+	if err := downloadFile(videoURL, outputFile, logf); err != nil {
+		logf("Error downloading video: %v", err)
 		result.Error = err
 		return result
 	}
 
 	result.VideoFile = outputFile
 	result.Success = true
-	log.Printf("Successfully downloaded Instagram video: %s", outputFile)
+	logf("Successfully downloaded Instagram video: %s", outputFile)
 
 	return result
 }
 
+// logger returns log.Printf, or a logger that discards everything when redact is set
+func logger(redact bool) func(format string, v ...interface{}) {
+	if redact {
+		return func(string, ...interface{}) {}
+	}
+	return log.Printf
+}
+
 // fetchVideoInfoFromGraphQL makes a GraphQL request to get video URL
-func fetchVideoInfoFromGraphQL(shortcode string) (string, error) {
+func fetchVideoInfoFromGraphQL(shortcode string, logf func(string, ...interface{})) (string, error) {
 	formData := buildGraphQLRequestBody(shortcode)
-	log.Printf("Request body: %s", formData)
+	logf("Request body: %s", formData)
 
 	req, err := http.NewRequest("POST", "https://www.instagram.com/api/graphql", strings.NewReader(formData))
 	if err != nil {
@@ -190,8 +201,8 @@ func extractShortcode(instaURL string) (string, error) {
 }
 
 // downloadFile downloads a file from URL and saves it locally
-func downloadFile(url, filepath string) error {
-	log.Printf("Downloading: %s", filepath)
+func downloadFile(url, filepath string, logf func(string, ...interface{})) error {
+	logf("Downloading: %s", filepath)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -0,0 +1,237 @@
+// Package linkpreview builds link previews from a page's Open Graph metadata.
+package linkpreview
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Limits on what is downloaded for a preview
+const (
+	DefaultTimeout    = 5 * time.Second
+	maxRedirects      = 5
+	maxPageBytes      = 512 << 10
+	maxImageBytes     = 1 << 20
+	maxDescriptionLen = 300
+)
+
+var (
+	urlRe   = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+	metaRe  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrRe  = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// Preview is the metadata shown for a link
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	Image       []byte // thumbnail, empty when the page has none
+}
+
+// Fetcher downloads pages and their preview images
+type Fetcher struct {
+	HTTPClient *http.Client
+}
+
+// New creates a Fetcher that makes its requests with httpClient, or with
+// NewHTTPClient(DefaultTimeout) when it is nil
+func New(httpClient *http.Client) *Fetcher {
+	if httpClient == nil {
+		httpClient = NewHTTPClient(DefaultTimeout)
+	}
+	return &Fetcher{HTTPClient: httpClient}
+}
+
+// NewHTTPClient returns a client for fetching previews that only connects to
+// public addresses. Links come from chat, so without this anyone in a chat
+// could have the bot fetch pages from its own network and post their titles.
+// The check runs on every connection, after DNS resolution and for each
+// redirect. timeout bounds each request.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicOnly}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: the address checked must be the one connected to.
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// publicOnly is a net.Dialer Control function refusing connections to
+// loopback, private, link-local and other non-public addresses
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublic(ip) {
+		return fmt.Errorf("refusing to fetch preview from non-public address %s", ip)
+	}
+	return nil
+}
+
+// nonPublic lists special-purpose ranges not covered by the netip predicates
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach IPv4 private ranges
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// isPublic reports whether ip is a globally routable unicast address
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// FirstURL returns the first http or https URL in text, or "" if there is none
+func FirstURL(text string) string {
+	return strings.TrimRight(urlRe.FindString(text), ".,;:!?*_~`")
+}
+
+// Fetch builds a preview of pageURL from its og:title, og:description and
+// og:image, falling back to the page title. A page without a title has no
+// preview.
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*Preview, error) {
+	page, _, err := f.get(ctx, pageURL, maxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+	meta := openGraph(string(page))
+	p := &Preview{
+		URL:         pageURL,
+		Title:       meta["og:title"],
+		Description: meta["og:description"],
+	}
+	if p.Title == "" {
+		if m := titleRe.FindStringSubmatch(string(page)); m != nil {
+			p.Title = clean(m[1])
+		}
+	}
+	if p.Title == "" {
+		return nil, fmt.Errorf("%s has no title", pageURL)
+	}
+	if p.Description == "" {
+		p.Description = meta["description"]
+	}
+	if len(p.Description) > maxDescriptionLen {
+		p.Description = strings.ToValidUTF8(p.Description[:maxDescriptionLen], "") + "…"
+	}
+
+	// The preview is still useful without its image.
+	if imageURL := resolve(pageURL, meta["og:image"]); imageURL != "" {
+		if img, contentType, err := f.get(ctx, imageURL, maxImageBytes); err == nil && strings.HasPrefix(contentType, "image/") {
+			p.Image = img
+		}
+	}
+	return p, nil
+}
+
+// get downloads target, refusing bodies larger than limit, and returns the
+// body with its content type
+func (f *Fetcher) get(ctx context.Context, target string, limit int64) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; signal-llm-bot link preview)")
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s: status %d", target, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(body)) > limit {
+		return nil, "", fmt.Errorf("fetching %s: larger than %d bytes", target, limit)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// openGraph collects the content of the page's meta tags by property or name.
+// The first value of each key wins.
+func openGraph(page string) map[string]string {
+	meta := make(map[string]string)
+	for _, tag := range metaRe.FindAllString(page, -1) {
+		attrs := make(map[string]string)
+		for _, a := range attrRe.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(a[1])] = strings.Trim(a[2], `"'`)
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if _, ok := meta[key]; key != "" && !ok {
+			meta[key] = clean(attrs["content"])
+		}
+	}
+	return meta
+}
+
+// clean unescapes HTML entities and collapses whitespace
+func clean(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// resolve makes ref absolute relative to base; it returns "" for empty or
+// non-http references
+func resolve(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	u, err := b.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
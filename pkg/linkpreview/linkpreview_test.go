@@ -0,0 +1,101 @@
+package linkpreview
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFirstURL(t *testing.T) {
+	tests := map[string]string{
+		"see https://example.com/a?b=1.":            "https://example.com/a?b=1",
+		"(source: http://example.org/page)":         "http://example.org/page",
+		"**https://example.com/bold**":              "https://example.com/bold",
+		"no links here, not even ftp://example.com": "",
+	}
+	for text, want := range tests {
+		if got := FirstURL(text); got != want {
+			t.Errorf("FirstURL(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestOpenGraph(t *testing.T) {
+	page := `<head>
+<meta property="og:title" content="Tom &amp; Jerry">
+<meta property='og:title' content='second title'>
+<META NAME="description" CONTENT="  spaced
+  out  ">
+<meta charset="utf-8">
+</head>`
+	meta := openGraph(page)
+	if meta["og:title"] != "Tom & Jerry" {
+		t.Errorf("og:title = %q, want the first value unescaped", meta["og:title"])
+	}
+	if meta["description"] != "spaced out" {
+		t.Errorf("description = %q", meta["description"])
+	}
+	if _, ok := meta[""]; ok {
+		t.Error("meta tag without property or name was recorded")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	base := "https://example.com/articles/1"
+	tests := map[string]string{
+		"/img/a.png":                 "https://example.com/img/a.png",
+		"b.png":                      "https://example.com/articles/b.png",
+		"//cdn.example.com/c.png":    "https://cdn.example.com/c.png",
+		"http://other.example/d.png": "http://other.example/d.png",
+		"data:image/png;base64,AAAA": "",
+		"javascript:alert(1)":        "",
+		"":                           "",
+	}
+	for ref, want := range tests {
+		if got := resolve(base, ref); got != want {
+			t.Errorf("resolve(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestFetch_SizeLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>big</title>"))
+		w.Write([]byte(strings.Repeat(" ", maxPageBytes)))
+	}))
+	defer srv.Close()
+
+	if p, err := New(srv.Client()).Fetch(context.Background(), srv.URL); err == nil {
+		t.Fatalf("Fetch() = %+v, expected an error for a page over the limit", p)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fc00::1", "::ffff:127.0.0.1", "224.0.0.1"} {
+		if isPublic(netip.MustParseAddr(addr)) {
+			t.Errorf("%s reported as public", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		if !isPublic(netip.MustParseAddr(addr)) {
+			t.Errorf("%s reported as non-public", addr)
+		}
+	}
+}
+
+func TestNewHTTPClient_RefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>internal</title>"))
+	}))
+	defer srv.Close()
+
+	f := New(NewHTTPClient(time.Second))
+	if p, err := f.Fetch(context.Background(), srv.URL); err == nil {
+		t.Fatalf("Fetch() = %+v, expected loopback to be refused", p)
+	}
+}
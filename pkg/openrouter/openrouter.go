@@ -18,11 +18,26 @@ type Client struct {
 	Model        string
 	Timeout      time.Duration
 	SystemPrompt string
+	HTTPClient   *http.Client
 }
 
 // New creates a new OpenRouter client.
 func New(apiKey, endpoint, model string, timeout time.Duration, systemPrompt string) *Client {
-	return &Client{APIKey: apiKey, Endpoint: endpoint, Model: model, Timeout: timeout, SystemPrompt: systemPrompt}
+	return &Client{APIKey: apiKey, Endpoint: endpoint, Model: model, Timeout: timeout, SystemPrompt: systemPrompt, HTTPClient: &http.Client{}}
+}
+
+// WithPersona returns a copy of the client that uses a different model and
+// system prompt but shares its key and connections. Empty values keep the
+// client's own.
+func (c *Client) WithPersona(model, systemPrompt string) *Client {
+	clone := *c
+	if model != "" {
+		clone.Model = model
+	}
+	if systemPrompt != "" {
+		clone.SystemPrompt = systemPrompt
+	}
+	return &clone
 }
 
 // Ask sends a prompt to OpenRouter and returns the response text.
@@ -56,7 +71,10 @@ func (c *Client) Ask(prompt string) (string, error) {
 		req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	}
 
-	client := http.Client{}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
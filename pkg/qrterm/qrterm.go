@@ -0,0 +1,94 @@
+// Package qrterm renders a QR code image as text for display in a terminal.
+package qrterm
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strings"
+)
+
+// quietZone is the light border, in modules, scanners need around the code
+const quietZone = 2
+
+// Render samples the QR code in img, which must be an upright, unrotated
+// rendering such as a generated PNG, and draws it with half-block characters,
+// two module rows per line. By default light modules are drawn as blocks,
+// which suits terminals with a dark background; invert draws dark modules
+// as blocks instead.
+func Render(img image.Image, invert bool) (string, error) {
+	modules, err := sample(img)
+	if err != nil {
+		return "", err
+	}
+	n := len(modules)
+	dark := func(x, y int) bool {
+		x, y = x-quietZone, y-quietZone
+		return x >= 0 && y >= 0 && x < n && y < n && modules[y][x]
+	}
+
+	size := n + 2*quietZone
+	var sb strings.Builder
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			top := dark(x, y) == invert
+			bottom := y+1 < size && dark(x, y+1) == invert
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// sample reads the module grid of the QR code in img. The module size is
+// taken from the top-left finder pattern, which is seven modules wide.
+func sample(img image.Image) ([][]bool, error) {
+	b := img.Bounds()
+	isDark := func(x, y int) bool {
+		r, g, bl, _ := img.At(x, y).RGBA()
+		return (299*r+587*g+114*bl)/1000 < 0x8000
+	}
+
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isDark(x, y) {
+				minX, maxX = min(minX, x), max(maxX, x)
+				minY, maxY = min(minY, y), max(maxY, y)
+			}
+		}
+	}
+	if maxX < minX {
+		return nil, errors.New("qrterm: image has no dark pixels")
+	}
+
+	run := 0
+	for x := minX; x <= maxX && isDark(x, minY); x++ {
+		run++
+	}
+	moduleSize := float64(run) / 7
+	n := int(math.Round(float64(maxX-minX+1) / moduleSize))
+	if moduleSize < 1 || n < 21 || n > 177 || (n-17)%4 != 0 {
+		return nil, errors.New("qrterm: image does not look like a QR code")
+	}
+
+	modules := make([][]bool, n)
+	for my := range modules {
+		modules[my] = make([]bool, n)
+		y := minY + int((float64(my)+0.5)*moduleSize)
+		for mx := range modules[my] {
+			x := minX + int((float64(mx)+0.5)*moduleSize)
+			modules[my][mx] = isDark(x, y)
+		}
+	}
+	return modules, nil
+}
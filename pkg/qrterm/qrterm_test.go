@@ -0,0 +1,141 @@
+package qrterm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// testGrid returns a version 1 (21×21) module grid with the three finder
+// patterns, their light separators and pseudo-random data elsewhere
+func testGrid() [][]bool {
+	const n = 21
+	rng := rand.New(rand.NewPCG(1, 2))
+	grid := make([][]bool, n)
+	for y := range grid {
+		grid[y] = make([]bool, n)
+		for x := range grid[y] {
+			grid[y][x] = rng.IntN(2) == 0
+		}
+	}
+	finder := func(ox, oy int) {
+		for y := -1; y <= 7; y++ {
+			for x := -1; x <= 7; x++ {
+				gx, gy := ox+x, oy+y
+				if gx < 0 || gy < 0 || gx >= n || gy >= n {
+					continue
+				}
+				ring := max(abs(x-3), abs(y-3))
+				grid[gy][gx] = ring != 2 && ring <= 3
+			}
+		}
+	}
+	finder(0, 0)
+	finder(n-7, 0)
+	finder(0, n-7)
+	return grid
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// encode draws grid as a PNG with the given module size and a light margin,
+// like the REST API's /v1/qrcodelink, and decodes it again
+func encode(t *testing.T, grid [][]bool, module, margin int) image.Image {
+	t.Helper()
+	size := len(grid)*module + 2*margin
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	for my, row := range grid {
+		for mx, dark := range row {
+			if !dark {
+				continue
+			}
+			for y := 0; y < module; y++ {
+				for x := 0; x < module; x++ {
+					img.SetGray(margin+mx*module+x, margin+my*module+y, color.Gray{})
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestSample(t *testing.T) {
+	grid := testGrid()
+	modules, err := sample(encode(t, grid, 5, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != len(grid) {
+		t.Fatalf("sampled %d modules per side, want %d", len(modules), len(grid))
+	}
+	for y := range grid {
+		for x := range grid[y] {
+			if modules[y][x] != grid[y][x] {
+				t.Fatalf("module (%d,%d) = %v, want %v", x, y, modules[y][x], grid[y][x])
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	grid := testGrid()
+	out, err := Render(encode(t, grid, 3, 12), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	size := len(grid) + 2*quietZone
+	if len(lines) != (size+1)/2 {
+		t.Fatalf("got %d lines, want %d", len(lines), (size+1)/2)
+	}
+	// The first line is quiet zone only, drawn as light (full) blocks.
+	if lines[0] != strings.Repeat("█", size) {
+		t.Errorf("first line %q is not quiet zone", lines[0])
+	}
+	// The second line pairs module rows 0 and 1 of the top-left finder
+	// pattern: dark edges are spaces, its light inner ring a lower half block.
+	row := []rune(lines[1])
+	if len(row) != size || string(row[quietZone:quietZone+7]) != " ▄▄▄▄▄ " {
+		t.Errorf("finder pattern rendered as %q", lines[1])
+	}
+
+	inverted, err := Render(encode(t, grid, 3, 12), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Split(inverted, "\n")[0], "█") {
+		t.Errorf("inverted quiet zone is not blank: %q", strings.Split(inverted, "\n")[0])
+	}
+}
+
+func TestSample_NotAQRCode(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	if _, err := sample(img); err == nil {
+		t.Error("expected an error for a blank image")
+	}
+}